@@ -0,0 +1,50 @@
+// Command scenario-runner loads the same scenarios.yaml used by
+// /diagnostics/scenarios and runs it standalone, for CI: it exits
+// non-zero if any scenario fails, so a pipeline can gate merges to
+// track-versions.yaml on the compatibility policy staying correct.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"talos-automation/internal/scenarios"
+)
+
+func main() {
+	path := flag.String("scenarios", "scenarios.yaml", "path to the scenarios YAML file")
+	jsonOutput := flag.Bool("json", false, "print the full JUnit-style report as JSON instead of a summary")
+	flag.Parse()
+
+	scns, err := scenarios.Load(*path)
+	if err != nil {
+		log.Fatalf("failed to load scenarios: %v", err)
+	}
+
+	report := scenarios.RunMatrix(scns)
+
+	if *jsonOutput {
+		if err := json.NewEncoder(os.Stdout).Encode(report); err != nil {
+			log.Fatalf("failed to encode report: %v", err)
+		}
+	} else {
+		for _, c := range report.Cases {
+			status := "PASS"
+			if !c.Passed {
+				status = "FAIL"
+			}
+			fmt.Printf("[%s] %s (expected %s, got %s)\n", status, c.Name, c.ExpectedTransition, c.ActualTransition)
+			if c.Message != "" {
+				fmt.Printf("       %s\n", c.Message)
+			}
+		}
+		fmt.Printf("\n%d/%d scenarios passed\n", report.Passed, report.Total)
+	}
+
+	if !report.Ready() {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,119 @@
+// Package compatibility enforces the upgrade preconditions that gate
+// processUpgrade: no unexpected downgrades, no more-than-one-minor hops,
+// and a target Kubernetes version that falls within the tested range for
+// the target Talos minor. It runs ahead of any real upgrade, so both
+// /webhook and /diagnostics can surface the same verdict.
+package compatibility
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"talos-automation/internal/semver"
+)
+
+// talosKubernetesSupport is the Talos minor -> [minKubernetes,
+// maxKubernetes] tested range. Mirrors the table published in the Talos
+// release notes; update alongside new Talos minors. This is the single
+// source of truth for the support matrix: upgrades.UpgradePlanner reads
+// it via SupportedKubernetesRange instead of keeping its own copy.
+var talosKubernetesSupport = map[string][2]string{
+	"1.7":  {"1.27", "1.30"},
+	"1.8":  {"1.28", "1.31"},
+	"1.9":  {"1.29", "1.32"},
+	"1.10": {"1.30", "1.33"},
+}
+
+// SupportedKubernetesRange returns the tested [min, max] Kubernetes minor
+// range for a Talos minor, e.g. SupportedKubernetesRange("1.10") ->
+// ("1.30", "1.33", true).
+func SupportedKubernetesRange(talosMinor string) (min, max string, ok bool) {
+	r, ok := talosKubernetesSupport[talosMinor]
+	if !ok {
+		return "", "", false
+	}
+	return r[0], r[1], true
+}
+
+const maxMinorHop = 1
+
+// CheckResult is the structured verdict returned by CheckUpgrade.
+type CheckResult struct {
+	Allowed bool     `json:"allowed"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// CheckUpgrade validates a proposed (currentTalos, currentKubernetes) ->
+// (targetTalos, targetKubernetes) upgrade against the shared
+// compatibility policy. allowDowngrade mirrors the allowDowngrade field
+// from track-versions.yaml. forceSkipSkew mirrors the FORCE_SKIP_SKEW
+// escape hatch validateUpgradePath honors: it bypasses the minor-hop
+// checks below, but never the downgrade check.
+func CheckUpgrade(currentTalos, currentKubernetes, targetTalos, targetKubernetes string, allowDowngrade, forceSkipSkew bool) (*CheckResult, error) {
+	result := &CheckResult{Allowed: true}
+
+	if !allowDowngrade {
+		if semver.IsDowngrade(currentTalos, targetTalos) {
+			result.Allowed = false
+			result.Reasons = append(result.Reasons, fmt.Sprintf("Talos target %s is a downgrade from %s", targetTalos, currentTalos))
+		}
+		if semver.IsDowngrade(currentKubernetes, targetKubernetes) {
+			result.Allowed = false
+			result.Reasons = append(result.Reasons, fmt.Sprintf("Kubernetes target %s is a downgrade from %s", targetKubernetes, currentKubernetes))
+		}
+	}
+
+	if !forceSkipSkew {
+		if distance, err := semver.MinorDistance(currentTalos, targetTalos); err == nil && distance > maxMinorHop {
+			result.Allowed = false
+			result.Reasons = append(result.Reasons, fmt.Sprintf("Talos upgrade from %s to %s skips more than one minor version", currentTalos, targetTalos))
+		}
+
+		if distance, err := semver.MinorDistance(currentKubernetes, targetKubernetes); err == nil && distance > maxMinorHop {
+			result.Allowed = false
+			result.Reasons = append(result.Reasons, fmt.Sprintf("Kubernetes upgrade from %s to %s skips more than one minor version", currentKubernetes, targetKubernetes))
+		}
+	}
+
+	targetTalosMinor := semver.Minor(targetTalos)
+	targetKubernetesMinor := semver.Minor(targetKubernetes)
+	if min, max, ok := SupportedKubernetesRange(targetTalosMinor); ok {
+		if compareMinor(targetKubernetesMinor, min) < 0 || compareMinor(targetKubernetesMinor, max) > 0 {
+			result.Allowed = false
+			result.Reasons = append(result.Reasons, fmt.Sprintf(
+				"Kubernetes %s is outside the tested range [%s, %s] for Talos %s", targetKubernetes, min, max, targetTalosMinor))
+		}
+	}
+
+	return result, nil
+}
+
+// Error returns a single error combining all failure reasons, or nil if
+// the check passed.
+func (r *CheckResult) Error() error {
+	if r.Allowed {
+		return nil
+	}
+	return fmt.Errorf("upgrade blocked by compatibility check: %s", strings.Join(r.Reasons, "; "))
+}
+
+// compareMinor compares two "major.minor" strings numerically.
+func compareMinor(a, b string) int {
+	aMajor, aMinor := splitMinor(a)
+	bMajor, bMinor := splitMinor(b)
+	if aMajor != bMajor {
+		return aMajor - bMajor
+	}
+	return aMinor - bMinor
+}
+
+func splitMinor(v string) (major, minor int) {
+	parts := strings.SplitN(v, ".", 2)
+	if len(parts) != 2 {
+		return 0, 0
+	}
+	major, _ = strconv.Atoi(parts[0])
+	minor, _ = strconv.Atoi(parts[1])
+	return major, minor
+}
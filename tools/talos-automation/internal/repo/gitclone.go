@@ -0,0 +1,72 @@
+package repo
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// GitCloneSource is a repo.Source backed by a shallow clone of the
+// repository's working tree, used for offline/air-gapped clusters or
+// self-hosted forges with no REST API reachable from the cluster. Every
+// fetch re-clones into a fresh tempdir, trading a little latency for
+// never holding a stale checkout.
+type GitCloneSource struct {
+	URL        string // SSH or HTTPS remote URL
+	Branch     string
+	SSHKeyPath string
+	Secret     string
+}
+
+func NewGitCloneSource(gitURL, branch, sshKeyPath, secret string) *GitCloneSource {
+	if branch == "" {
+		branch = "main"
+	}
+	return &GitCloneSource{URL: gitURL, Branch: branch, SSHKeyPath: sshKeyPath, Secret: secret}
+}
+
+func (g *GitCloneSource) FetchVersions() (*Versions, error) {
+	data, err := g.readFile("infrastructure/cluster/track-versions.yaml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read versions file: %w", err)
+	}
+	var versions Versions
+	if err := yaml.Unmarshal(data, &versions); err != nil {
+		return nil, fmt.Errorf("failed to parse versions YAML: %w", err)
+	}
+	return &versions, nil
+}
+
+func (g *GitCloneSource) FetchBareMetalConfig() ([]byte, error) {
+	return g.readFile("infrastructure/cluster/bare-metal.yaml")
+}
+
+// VerifyWebhook falls back to the same HMAC scheme as GitHub's, since a
+// plain git remote has no forge-specific webhook format of its own; the
+// caller is expected to be whatever CI/cron forwards the push notice.
+func (g *GitCloneSource) VerifyWebhook(payload []byte, signatureHeader string) bool {
+	return VerifyHMACSignature(payload, signatureHeader, g.Secret)
+}
+
+func (g *GitCloneSource) readFile(relPath string) ([]byte, error) {
+	tmpDir, err := os.MkdirTemp("", "talos-automation-gitclone-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tempdir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	args := []string{"clone", "--depth", "1", "--branch", g.Branch, g.URL, tmpDir}
+	cmd := exec.Command("git", args...)
+	if g.SSHKeyPath != "" {
+		cmd.Env = append(os.Environ(), fmt.Sprintf("GIT_SSH_COMMAND=ssh -i %s -o IdentitiesOnly=yes", g.SSHKeyPath))
+	}
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("git clone failed: %v: %s", err, string(out))
+	}
+
+	return os.ReadFile(filepath.Join(tmpDir, relPath))
+}
@@ -0,0 +1,85 @@
+package repo
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"gopkg.in/yaml.v3"
+)
+
+// GiteaClient is a repo.Source backed by a Gitea (or Forgejo) instance's
+// contents API, which mirrors GitHub's shape closely enough to share the
+// same base64-content decoding.
+type GiteaClient struct {
+	Token   string
+	BaseURL string // e.g. "https://git.example.com"
+	Owner   string
+	Repo    string
+	Branch  string
+	Secret  string
+}
+
+func NewGiteaClient(baseURL, token, owner, repo, branch, secret string) *GiteaClient {
+	if branch == "" {
+		branch = "main"
+	}
+	return &GiteaClient{Token: token, BaseURL: baseURL, Owner: owner, Repo: repo, Branch: branch, Secret: secret}
+}
+
+func (g *GiteaClient) FetchVersions() (*Versions, error) {
+	data, err := g.fetchFileContent("infrastructure/cluster/track-versions.yaml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch versions file: %w", err)
+	}
+	var versions Versions
+	if err := yaml.Unmarshal(data, &versions); err != nil {
+		return nil, fmt.Errorf("failed to parse versions YAML: %w", err)
+	}
+	return &versions, nil
+}
+
+func (g *GiteaClient) FetchBareMetalConfig() ([]byte, error) {
+	return g.fetchFileContent("infrastructure/cluster/bare-metal.yaml")
+}
+
+// VerifyWebhook checks the X-Gitea-Signature header, an HMAC-SHA256 hex
+// digest with no "sha256=" prefix (unlike GitHub's).
+func (g *GiteaClient) VerifyWebhook(payload []byte, signatureHeader string) bool {
+	return VerifyHMACSignature(payload, "sha256="+signatureHeader, g.Secret)
+}
+
+func (g *GiteaClient) fetchFileContent(filePath string) ([]byte, error) {
+	reqURL := fmt.Sprintf("%s/api/v1/repos/%s/%s/contents/%s?ref=%s", g.BaseURL, g.Owner, g.Repo, filePath, g.Branch)
+
+	req, err := http.NewRequest("GET", reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if g.Token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("token %s", g.Token))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch file from Gitea API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("Gitea API error %d: %s", resp.StatusCode, string(body))
+	}
+
+	var content GitHubContent
+	if err := json.NewDecoder(resp.Body).Decode(&content); err != nil {
+		return nil, fmt.Errorf("failed to decode Gitea API response: %w", err)
+	}
+	if content.Encoding != "base64" {
+		return nil, fmt.Errorf("unexpected encoding: %s", content.Encoding)
+	}
+
+	return base64.StdEncoding.DecodeString(content.Content)
+}
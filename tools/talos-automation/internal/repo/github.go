@@ -1,11 +1,13 @@
 package repo
 
 import (
+	"bytes"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -13,23 +15,31 @@ import (
 type Versions struct {
 	TalosVersion      string `yaml:"talosVersion"`
 	KubernetesVersion string `yaml:"kubernetesVersion"`
+	KubeletVersion    string `yaml:"kubeletVersion"`
+	AllowDowngrade    bool   `yaml:"allowDowngrade"`
 }
 
 type GitHubContent struct {
 	Content  string `json:"content"`
 	Encoding string `json:"encoding"`
+	SHA      string `json:"sha"`
 }
 
+// GitHubClient is a repo.Source backed by the GitHub REST API, scoped to
+// a single owner/repo.
 type GitHubClient struct {
-	Token string
+	Token  string
+	Owner  string
+	Repo   string
+	Secret string
 }
 
-func NewGitHubClient(token string) *GitHubClient {
-	return &GitHubClient{Token: token}
+func NewGitHubClient(token, owner, repo, secret string) *GitHubClient {
+	return &GitHubClient{Token: token, Owner: owner, Repo: repo, Secret: secret}
 }
 
-func (g *GitHubClient) FetchVersions(owner, repo string) (*Versions, error) {
-	decoded, err := g.fetchFileContent(owner, repo, "infrastructure/cluster/track-versions.yaml")
+func (g *GitHubClient) FetchVersions() (*Versions, error) {
+	decoded, err := g.fetchFileContent("infrastructure/cluster/track-versions.yaml")
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch versions file: %w", err)
 	}
@@ -42,12 +52,223 @@ func (g *GitHubClient) FetchVersions(owner, repo string) (*Versions, error) {
 	return &versions, nil
 }
 
-func (g *GitHubClient) FetchBareMetalConfig(owner, repo string) ([]byte, error) {
-	return g.fetchFileContent(owner, repo, "infrastructure/cluster/bare-metal.yaml")
+func (g *GitHubClient) FetchBareMetalConfig() ([]byte, error) {
+	return g.fetchFileContent("infrastructure/cluster/bare-metal.yaml")
 }
 
-func (g *GitHubClient) fetchFileContent(owner, repo, filePath string) ([]byte, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/contents/%s", owner, repo, filePath)
+// VerifyWebhook checks the X-Hub-Signature-256 header against an
+// HMAC-SHA256 of payload keyed by g.Secret.
+func (g *GitHubClient) VerifyWebhook(payload []byte, signatureHeader string) bool {
+	return VerifyHMACSignature(payload, signatureHeader, g.Secret)
+}
+
+// appliedVersionsPath is the companion file ProposeVersionUpdate keeps in
+// sync with what's actually running on the cluster, as observed by
+// talos.ParseConfig plus live version queries, distinct from
+// track-versions.yaml which records what's desired.
+const appliedVersionsPath = "infrastructure/cluster/applied-versions.yaml"
+
+// ProposeVersionUpdate opens a pull request against main updating
+// applied-versions.yaml to content, with logTail (the tail of the
+// upgrade log) as the PR body, so the versions actually running on the
+// cluster stay reviewable in git. The pull request touches only
+// applied-versions.yaml, so merging or reverting it does not trigger an
+// upgrade.
+func (g *GitHubClient) ProposeVersionUpdate(content []byte, logTail string) (prURL string, err error) {
+	baseSHA, err := g.refSHA("heads/main")
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve main branch: %w", err)
+	}
+
+	branch := fmt.Sprintf("talos-automation/applied-versions-%d", time.Now().Unix())
+	if err := g.createBranch(branch, baseSHA); err != nil {
+		return "", fmt.Errorf("failed to create branch %s: %w", branch, err)
+	}
+
+	existingSHA, err := g.fileSHA(appliedVersionsPath, "main")
+	if err != nil {
+		return "", fmt.Errorf("failed to look up existing %s: %w", appliedVersionsPath, err)
+	}
+
+	if err := g.putFileContent(appliedVersionsPath, content, branch, existingSHA); err != nil {
+		return "", fmt.Errorf("failed to update %s on %s: %w", appliedVersionsPath, branch, err)
+	}
+
+	prURL, err = g.createPullRequest(branch, "main",
+		"Record applied Talos/Kubernetes versions",
+		fmt.Sprintf("Automated update of `%s` after a successful upgrade.\n\n<details>\n<summary>Upgrade log tail</summary>\n\n```\n%s\n```\n\n</details>\n", appliedVersionsPath, logTail))
+	if err != nil {
+		return "", fmt.Errorf("failed to open pull request: %w", err)
+	}
+
+	return prURL, nil
+}
+
+// refSHA returns the commit SHA a git ref (e.g. "heads/main") currently
+// points at.
+func (g *GitHubClient) refSHA(ref string) (string, error) {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/git/ref/%s", g.Owner, g.Repo, ref)
+
+	var result struct {
+		Object struct {
+			SHA string `json:"sha"`
+		} `json:"object"`
+	}
+	if err := g.doJSON("GET", url, nil, &result); err != nil {
+		return "", err
+	}
+	return result.Object.SHA, nil
+}
+
+// createBranch creates a new ref named branch pointing at sha.
+func (g *GitHubClient) createBranch(branch, sha string) error {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/git/refs", g.Owner, g.Repo)
+	body := map[string]string{
+		"ref": "refs/heads/" + branch,
+		"sha": sha,
+	}
+	return g.doJSON("POST", url, body, nil)
+}
+
+// fileSHA returns the blob SHA of an existing file at filePath on ref, or
+// "" if the file doesn't exist yet (so putFileContent knows to create it
+// rather than update it).
+func (g *GitHubClient) fileSHA(filePath, ref string) (string, error) {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/contents/%s?ref=%s", g.Owner, g.Repo, filePath, ref)
+
+	var content GitHubContent
+	err := g.doJSON("GET", url, nil, &content)
+	if err != nil {
+		if isNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return content.SHA, nil
+}
+
+// putFileContent creates or updates filePath on branch with content. An
+// empty existingSHA creates the file; a non-empty one updates it.
+func (g *GitHubClient) putFileContent(filePath string, content []byte, branch, existingSHA string) error {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/contents/%s", g.Owner, g.Repo, filePath)
+	body := map[string]interface{}{
+		"message": fmt.Sprintf("Update %s", filePath),
+		"content": base64.StdEncoding.EncodeToString(content),
+		"branch":  branch,
+	}
+	if existingSHA != "" {
+		body["sha"] = existingSHA
+	}
+	return g.doJSON("PUT", url, body, nil)
+}
+
+// createPullRequest opens a PR from head into base and returns its HTML
+// URL.
+func (g *GitHubClient) createPullRequest(head, base, title, body string) (string, error) {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/pulls", g.Owner, g.Repo)
+	reqBody := map[string]string{
+		"title": title,
+		"head":  head,
+		"base":  base,
+		"body":  body,
+	}
+
+	var result struct {
+		HTMLURL string `json:"html_url"`
+	}
+	if err := g.doJSON("POST", url, reqBody, &result); err != nil {
+		return "", err
+	}
+	return result.HTMLURL, nil
+}
+
+// httpStatusError carries the HTTP status code from a GitHub API call so
+// callers like fileSHA can distinguish "not found" from other failures.
+type httpStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("GitHub API error %d: %s", e.StatusCode, e.Body)
+}
+
+func isNotFound(err error) bool {
+	statusErr, ok := err.(*httpStatusError)
+	return ok && statusErr.StatusCode == http.StatusNotFound
+}
+
+// doJSON issues a GitHub API request, JSON-encoding reqBody if non-nil
+// and JSON-decoding the response into out if non-nil.
+func (g *GitHubClient) doJSON(method, url string, reqBody, out interface{}) error {
+	var bodyReader io.Reader
+	if reqBody != nil {
+		data, err := json.Marshal(reqBody)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request body: %w", err)
+		}
+		bodyReader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, url, bodyReader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if g.Token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("token %s", g.Token))
+	}
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	if reqBody != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call GitHub API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read GitHub API response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &httpStatusError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("failed to decode GitHub API response: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// PullRequestTouchesFile reports whether the pull request numbered
+// number modified filePath, so a pull_request webhook handler can scope
+// its reaction to track-versions.yaml the same way the push handler
+// does.
+func (g *GitHubClient) PullRequestTouchesFile(number int, filePath string) (bool, error) {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/pulls/%d/files", g.Owner, g.Repo, number)
+
+	var files []struct {
+		Filename string `json:"filename"`
+	}
+	if err := g.doJSON("GET", url, nil, &files); err != nil {
+		return false, err
+	}
+	for _, f := range files {
+		if f.Filename == filePath {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (g *GitHubClient) fetchFileContent(filePath string) ([]byte, error) {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/contents/%s", g.Owner, g.Repo, filePath)
 
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
@@ -85,4 +306,4 @@ func (g *GitHubClient) fetchFileContent(owner, repo, filePath string) ([]byte, e
 	}
 
 	return decoded, nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,80 @@
+package repo
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"gopkg.in/yaml.v3"
+)
+
+// GitLabClient is a repo.Source backed by the GitLab REST v4 API,
+// scoped to a single project (owner/repo, URL-encoded as GitLab expects
+// for the project path).
+type GitLabClient struct {
+	Token     string
+	BaseURL   string // e.g. "https://gitlab.com", for self-hosted instances
+	ProjectID string // "owner/repo" or a numeric project ID
+	Branch    string
+	Secret    string
+}
+
+func NewGitLabClient(baseURL, token, projectID, branch, secret string) *GitLabClient {
+	if baseURL == "" {
+		baseURL = "https://gitlab.com"
+	}
+	if branch == "" {
+		branch = "main"
+	}
+	return &GitLabClient{Token: token, BaseURL: baseURL, ProjectID: projectID, Branch: branch, Secret: secret}
+}
+
+func (g *GitLabClient) FetchVersions() (*Versions, error) {
+	data, err := g.fetchFileContent("infrastructure/cluster/track-versions.yaml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch versions file: %w", err)
+	}
+	var versions Versions
+	if err := yaml.Unmarshal(data, &versions); err != nil {
+		return nil, fmt.Errorf("failed to parse versions YAML: %w", err)
+	}
+	return &versions, nil
+}
+
+func (g *GitLabClient) FetchBareMetalConfig() ([]byte, error) {
+	return g.fetchFileContent("infrastructure/cluster/bare-metal.yaml")
+}
+
+// VerifyWebhook checks the X-Gitlab-Token header for an exact match
+// against the configured secret (GitLab webhooks use a static token, not
+// an HMAC, per its webhook design).
+func (g *GitLabClient) VerifyWebhook(payload []byte, tokenHeader string) bool {
+	return g.Secret != "" && tokenHeader == g.Secret
+}
+
+func (g *GitLabClient) fetchFileContent(filePath string) ([]byte, error) {
+	reqURL := fmt.Sprintf("%s/api/v4/projects/%s/repository/files/%s/raw?ref=%s",
+		g.BaseURL, url.PathEscape(g.ProjectID), url.PathEscape(filePath), url.QueryEscape(g.Branch))
+
+	req, err := http.NewRequest("GET", reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if g.Token != "" {
+		req.Header.Set("PRIVATE-TOKEN", g.Token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch file from GitLab API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("GitLab API error %d: %s", resp.StatusCode, string(body))
+	}
+
+	return io.ReadAll(resp.Body)
+}
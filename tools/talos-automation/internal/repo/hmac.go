@@ -0,0 +1,23 @@
+package repo
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// VerifyHMACSignature checks signatureHeader (expected form
+// "sha256=<hex>", as GitHub and most forges send it) against an
+// HMAC-SHA256 of payload keyed by secret.
+func VerifyHMACSignature(payload []byte, signatureHeader, secret string) bool {
+	if !strings.HasPrefix(signatureHeader, "sha256=") {
+		return false
+	}
+
+	expectedMAC := hmac.New(sha256.New, []byte(secret))
+	expectedMAC.Write(payload)
+	expected := "sha256=" + hex.EncodeToString(expectedMAC.Sum(nil))
+
+	return hmac.Equal([]byte(signatureHeader), []byte(expected))
+}
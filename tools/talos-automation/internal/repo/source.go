@@ -0,0 +1,22 @@
+package repo
+
+// Source is the abstraction processUpgrade and diagnosticsEndpoint
+// program against, so the same webhook-driven upgrade flow can run
+// against GitHub, GitLab, Gitea, or a plain git remote. Each
+// implementation is scoped to a single owner/repo (or URL) at
+// construction time.
+type Source interface {
+	// FetchVersions reads and parses
+	// infrastructure/cluster/track-versions.yaml from the source.
+	FetchVersions() (*Versions, error)
+
+	// FetchBareMetalConfig reads
+	// infrastructure/cluster/bare-metal.yaml from the source.
+	FetchBareMetalConfig() ([]byte, error)
+
+	// VerifyWebhook validates an inbound webhook request's signature
+	// against the configured secret, given the raw request body and the
+	// signature-bearing header value (e.g. X-Hub-Signature-256 for
+	// GitHub, X-Gitlab-Token for GitLab).
+	VerifyWebhook(payload []byte, signatureHeader string) bool
+}
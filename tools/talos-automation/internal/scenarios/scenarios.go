@@ -0,0 +1,155 @@
+// Package scenarios turns the ad-hoc scenario/current_k8s/current_talos
+// query params that diagnosticsEndpoint grew over time into a declarative
+// test matrix: each scenario states a starting (fromTalos, fromKubernetes)
+// pair, a target (toTalos, toKubernetes) pair, the transition it expects
+// the compatibility policy to produce, and (optionally) a substring any
+// resulting error must contain. RunMatrix evaluates the whole file against
+// the real compatibility package without mutating a cluster, so both
+// /diagnostics/scenarios and cmd/scenario-runner can share it.
+package scenarios
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"talos-automation/internal/compatibility"
+)
+
+// Transition is the upgrade shape a scenario expects: which components
+// change and whether the compatibility policy allows it at all.
+type Transition string
+
+const (
+	TransitionNoop           Transition = "noop"
+	TransitionTalosOnly      Transition = "talos-only"
+	TransitionKubernetesOnly Transition = "k8s-only"
+	TransitionBoth           Transition = "both"
+	TransitionBlockedBySkew  Transition = "blocked-by-skew"
+)
+
+// Scenario is a single declarative test case, loaded from YAML.
+type Scenario struct {
+	Name                   string     `yaml:"name"`
+	FromTalos              string     `yaml:"fromTalos"`
+	FromKubernetes         string     `yaml:"fromKubernetes"`
+	ToTalos                string     `yaml:"toTalos"`
+	ToKubernetes           string     `yaml:"toKubernetes"`
+	AllowDowngrade         bool       `yaml:"allowDowngrade"`
+	ExpectedTransition     Transition `yaml:"expectedTransition"`
+	ExpectedErrorSubstring string     `yaml:"expectedErrorSubstring,omitempty"`
+}
+
+// File is the top-level shape of a scenarios YAML file.
+type File struct {
+	Scenarios []Scenario `yaml:"scenarios"`
+}
+
+// Load reads and parses a scenarios YAML file from path.
+func Load(path string) ([]Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenarios file: %w", err)
+	}
+
+	var f File
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse scenarios file: %w", err)
+	}
+
+	return f.Scenarios, nil
+}
+
+// CaseResult is a single scenario's outcome, shaped like a JUnit test
+// case: a name, pass/fail, and a message explaining a failure.
+type CaseResult struct {
+	Name               string     `json:"name"`
+	Passed             bool       `json:"passed"`
+	ExpectedTransition Transition `json:"expectedTransition"`
+	ActualTransition   Transition `json:"actualTransition"`
+	Message            string     `json:"message,omitempty"`
+}
+
+// Report is the JUnit-style summary of a full RunMatrix call.
+type Report struct {
+	Total  int          `json:"total"`
+	Passed int          `json:"passed"`
+	Failed int          `json:"failed"`
+	Cases  []CaseResult `json:"cases"`
+}
+
+// Ready reports whether every scenario in the report passed.
+func (r *Report) Ready() bool {
+	return r.Failed == 0
+}
+
+// RunMatrix evaluates every scenario against the real compatibility
+// policy (no real cluster mutation: it never invokes UpgradeToVersion)
+// and returns a JUnit-style report of which scenarios produced the
+// transition they declared.
+func RunMatrix(scns []Scenario) *Report {
+	report := &Report{Total: len(scns)}
+
+	for _, s := range scns {
+		result := evaluate(s)
+		report.Cases = append(report.Cases, result)
+		if result.Passed {
+			report.Passed++
+		} else {
+			report.Failed++
+		}
+	}
+
+	return report
+}
+
+func evaluate(s Scenario) CaseResult {
+	result := CaseResult{Name: s.Name, ExpectedTransition: s.ExpectedTransition}
+
+	if s.FromTalos == s.ToTalos && s.FromKubernetes == s.ToKubernetes {
+		result.ActualTransition = TransitionNoop
+		return finish(result)
+	}
+
+	check, err := compatibility.CheckUpgrade(s.FromTalos, s.FromKubernetes, s.ToTalos, s.ToKubernetes, s.AllowDowngrade, false)
+	if err != nil {
+		result.Message = fmt.Sprintf("compatibility check errored: %v", err)
+		return result
+	}
+
+	if !check.Allowed {
+		result.ActualTransition = TransitionBlockedBySkew
+		if s.ExpectedErrorSubstring != "" && !strings.Contains(check.Error().Error(), s.ExpectedErrorSubstring) {
+			result.Message = fmt.Sprintf("expected error to contain %q, got %q", s.ExpectedErrorSubstring, check.Error().Error())
+			return result
+		}
+		return finish(result)
+	}
+
+	talosChanged := s.FromTalos != s.ToTalos
+	kubernetesChanged := s.FromKubernetes != s.ToKubernetes
+	switch {
+	case talosChanged && kubernetesChanged:
+		result.ActualTransition = TransitionBoth
+	case talosChanged:
+		result.ActualTransition = TransitionTalosOnly
+	case kubernetesChanged:
+		result.ActualTransition = TransitionKubernetesOnly
+	default:
+		result.ActualTransition = TransitionNoop
+	}
+
+	return finish(result)
+}
+
+// finish compares the actual transition against what the scenario
+// declared and fills in Passed/Message accordingly.
+func finish(result CaseResult) CaseResult {
+	result.Passed = result.ActualTransition == result.ExpectedTransition
+	if !result.Passed {
+		result.Message = fmt.Sprintf("expected transition %q, got %q", result.ExpectedTransition, result.ActualTransition)
+	}
+	return result
+}
@@ -0,0 +1,73 @@
+// Package semver provides the version comparison helpers shared by the
+// Talos and Kubernetes upgraders. It wraps golang.org/x/mod/semver, which
+// requires a leading "v", so callers can keep passing the bare
+// "major.minor.patch" strings used throughout this repo.
+package semver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	xsemver "golang.org/x/mod/semver"
+)
+
+func canonical(version string) string {
+	if strings.HasPrefix(version, "v") {
+		return version
+	}
+	return "v" + version
+}
+
+// Compare returns -1, 0, or 1 depending on whether a is less than, equal
+// to, or greater than b.
+func Compare(a, b string) int {
+	return xsemver.Compare(canonical(a), canonical(b))
+}
+
+// IsDowngrade reports whether target is strictly less than current.
+func IsDowngrade(current, target string) bool {
+	return Compare(target, current) < 0
+}
+
+// Minor returns the "major.minor" portion of a version string, e.g.
+// "1.33.4" -> "1.33", without the "v" prefix canonical() adds.
+func Minor(version string) string {
+	return strings.TrimPrefix(xsemver.MajorMinor(canonical(version)), "v")
+}
+
+// MinorDistance returns target's minor number minus current's minor
+// number, e.g. MinorDistance("1.31.0", "1.33.0") == 2. Major versions must
+// match, since the skew policies this supports (Kubernetes, Talos) don't
+// span major versions.
+func MinorDistance(current, target string) (int, error) {
+	currentMajor, currentMinor, err := majorMinorInts(current)
+	if err != nil {
+		return 0, fmt.Errorf("invalid current version %q: %w", current, err)
+	}
+	targetMajor, targetMinor, err := majorMinorInts(target)
+	if err != nil {
+		return 0, fmt.Errorf("invalid target version %q: %w", target, err)
+	}
+	if currentMajor != targetMajor {
+		return 0, fmt.Errorf("major version mismatch: %s vs %s", current, target)
+	}
+	return targetMinor - currentMinor, nil
+}
+
+func majorMinorInts(version string) (major, minor int, err error) {
+	mm := strings.TrimPrefix(xsemver.MajorMinor(canonical(version)), "v")
+	parts := strings.SplitN(mm, ".", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("could not parse major.minor from %q", version)
+	}
+	major, err = strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	minor, err = strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	return major, minor, nil
+}
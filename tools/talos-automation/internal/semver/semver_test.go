@@ -0,0 +1,63 @@
+package semver
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"1.31.0", "1.31.0", 0},
+		{"1.31.0", "1.32.0", -1},
+		{"1.32.0", "1.31.0", 1},
+		{"v1.9.0", "1.9.0", 0},
+	}
+	for _, c := range cases {
+		if got := Compare(c.a, c.b); got != c.want {
+			t.Errorf("Compare(%q, %q) = %d, want %d", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestIsDowngrade(t *testing.T) {
+	if !IsDowngrade("1.32.0", "1.31.0") {
+		t.Error("expected 1.32.0 -> 1.31.0 to be a downgrade")
+	}
+	if IsDowngrade("1.31.0", "1.32.0") {
+		t.Error("did not expect 1.31.0 -> 1.32.0 to be a downgrade")
+	}
+	if IsDowngrade("1.31.0", "1.31.0") {
+		t.Error("did not expect equal versions to be a downgrade")
+	}
+}
+
+func TestMinor(t *testing.T) {
+	if got := Minor("1.33.4"); got != "1.33" {
+		t.Errorf("Minor(%q) = %q, want 1.33", "1.33.4", got)
+	}
+	if got := Minor("v1.33.4"); got != "1.33" {
+		t.Errorf("Minor(%q) = %q, want 1.33", "v1.33.4", got)
+	}
+}
+
+func TestMinorDistance(t *testing.T) {
+	dist, err := MinorDistance("1.31.0", "1.33.0")
+	if err != nil {
+		t.Fatalf("MinorDistance returned error: %v", err)
+	}
+	if dist != 2 {
+		t.Errorf("MinorDistance(1.31.0, 1.33.0) = %d, want 2", dist)
+	}
+
+	dist, err = MinorDistance("1.33.0", "1.31.0")
+	if err != nil {
+		t.Fatalf("MinorDistance returned error: %v", err)
+	}
+	if dist != -2 {
+		t.Errorf("MinorDistance(1.33.0, 1.31.0) = %d, want -2", dist)
+	}
+
+	if _, err := MinorDistance("1.31.0", "2.0.0"); err == nil {
+		t.Error("expected error for major version mismatch, got nil")
+	}
+}
@@ -48,4 +48,39 @@ func (c *Config) GetAllNodes() ([]string, error) {
 		}
 	}
 	return nil, fmt.Errorf("no nodes found in talosconfig")
+}
+
+// GetControlPlaneNodes returns the full set of control-plane nodes, i.e.
+// the context's endpoints (the nodes talosctl can reach the API on).
+func (c *Config) GetControlPlaneNodes() ([]string, error) {
+	for _, context := range c.Contexts {
+		if len(context.Endpoints) > 0 {
+			return context.Endpoints, nil
+		}
+	}
+	return nil, fmt.Errorf("no endpoints found in talosconfig")
+}
+
+// GetWorkerNodes returns every node that isn't also an endpoint.
+func (c *Config) GetWorkerNodes() ([]string, error) {
+	allNodes, err := c.GetAllNodes()
+	if err != nil {
+		return nil, err
+	}
+	controlPlaneNodes, err := c.GetControlPlaneNodes()
+	if err != nil {
+		return nil, err
+	}
+	controlPlaneSet := make(map[string]bool, len(controlPlaneNodes))
+	for _, node := range controlPlaneNodes {
+		controlPlaneSet[node] = true
+	}
+
+	var workers []string
+	for _, node := range allNodes {
+		if !controlPlaneSet[node] {
+			workers = append(workers, node)
+		}
+	}
+	return workers, nil
 }
\ No newline at end of file
@@ -1,26 +1,29 @@
 package main
 
 import (
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
+	"gopkg.in/yaml.v3"
+
+	"talos-automation/internal/compatibility"
 	"talos-automation/internal/repo"
+	"talos-automation/internal/scenarios"
 	"talos-automation/internal/talos"
 	"talos-automation/upgrades"
 )
 
 type GitHubWebhook struct {
-	Action string `json:"action"`
-	Ref    string `json:"ref"`
+	Action  string `json:"action"`
+	Ref     string `json:"ref"`
 	Commits []struct {
 		Modified []string `json:"modified"`
 	} `json:"commits"`
@@ -30,26 +33,49 @@ type GitHubWebhook struct {
 }
 
 type Config struct {
-	WebhookSecret    string
-	TalosConfigPath  string
-	LogPath          string
-	GitHubToken      string
-	GitHubOwner      string
-	GitHubRepo       string
-	Port             string
-	DiagnosticsToken string
+	WebhookSecret        string
+	TalosConfigPath      string
+	LogPath              string
+	GitHubToken          string
+	GitHubOwner          string
+	GitHubRepo           string
+	GitProvider          string
+	GitURL               string
+	GitBranch            string
+	GitSSHKeyPath        string
+	Port                 string
+	DiagnosticsToken     string
+	MaxUnavailable       int
+	ScenariosPath        string
+	ForceSkipSkew        bool
+	CosignPubKey         string
+	AllowSchematicChange bool
 }
 
 func loadConfig() *Config {
+	maxUnavailable, err := strconv.Atoi(getEnvWithDefault("MAX_UNAVAILABLE", "1"))
+	if err != nil || maxUnavailable < 1 {
+		maxUnavailable = 1
+	}
+
 	return &Config{
-		WebhookSecret:    os.Getenv("GITHUB_WEBHOOK_SECRET"),
-		TalosConfigPath:  os.Getenv("TALOS_CONFIG_PATH"),
-		LogPath:          os.Getenv("LOG_PATH"),
-		GitHubToken:      os.Getenv("GITHUB_TOKEN"),
-		GitHubOwner:      os.Getenv("GITHUB_OWNER"),
-		GitHubRepo:       os.Getenv("GITHUB_REPO"),
-		Port:             getEnvWithDefault("PORT", "3847"),
-		DiagnosticsToken: os.Getenv("DIAGNOSTICS_TOKEN"),
+		WebhookSecret:        os.Getenv("GITHUB_WEBHOOK_SECRET"),
+		TalosConfigPath:      os.Getenv("TALOS_CONFIG_PATH"),
+		LogPath:              os.Getenv("LOG_PATH"),
+		GitHubToken:          os.Getenv("GITHUB_TOKEN"),
+		GitHubOwner:          os.Getenv("GITHUB_OWNER"),
+		GitHubRepo:           os.Getenv("GITHUB_REPO"),
+		GitProvider:          getEnvWithDefault("GIT_PROVIDER", "github"),
+		GitURL:               os.Getenv("GIT_URL"),
+		GitBranch:            os.Getenv("GIT_BRANCH"),
+		GitSSHKeyPath:        os.Getenv("GIT_SSH_KEY_PATH"),
+		Port:                 getEnvWithDefault("PORT", "3847"),
+		DiagnosticsToken:     os.Getenv("DIAGNOSTICS_TOKEN"),
+		MaxUnavailable:       maxUnavailable,
+		ScenariosPath:        getEnvWithDefault("SCENARIOS_PATH", "scenarios.yaml"),
+		ForceSkipSkew:        getEnvWithDefault("FORCE_SKIP_SKEW", "false") == "true",
+		CosignPubKey:         os.Getenv("COSIGN_PUBLIC_KEY"),
+		AllowSchematicChange: getEnvWithDefault("ALLOW_SCHEMATIC_CHANGE", "false") == "true",
 	}
 }
 
@@ -60,16 +86,59 @@ func getEnvWithDefault(key, defaultValue string) string {
 	return defaultValue
 }
 
-func verifyWebhookSignature(payload []byte, signature string, secret string) bool {
-	if !strings.HasPrefix(signature, "sha256=") {
-		return false
+// newSource builds the repo.Source for the configured Git provider. GitHub
+// remains the default so existing deployments need no new environment
+// variables; GIT_PROVIDER opts into the other backends.
+func newSource(cfg *Config) (repo.Source, error) {
+	switch cfg.GitProvider {
+	case "", "github":
+		return repo.NewGitHubClient(cfg.GitHubToken, cfg.GitHubOwner, cfg.GitHubRepo, cfg.WebhookSecret), nil
+	case "gitlab":
+		return repo.NewGitLabClient(cfg.GitURL, cfg.GitHubToken, cfg.GitHubRepo, cfg.GitBranch, cfg.WebhookSecret), nil
+	case "gitea":
+		return repo.NewGiteaClient(cfg.GitURL, cfg.GitHubToken, cfg.GitHubOwner, cfg.GitHubRepo, cfg.GitBranch, cfg.WebhookSecret), nil
+	case "gitclone":
+		return repo.NewGitCloneSource(cfg.GitURL, cfg.GitBranch, cfg.GitSSHKeyPath, cfg.WebhookSecret), nil
+	default:
+		return nil, fmt.Errorf("unknown GIT_PROVIDER: %s", cfg.GitProvider)
 	}
+}
 
-	expectedMAC := hmac.New(sha256.New, []byte(secret))
-	expectedMAC.Write(payload)
-	expectedSignature := "sha256=" + hex.EncodeToString(expectedMAC.Sum(nil))
+// webhookSignatureHeader returns the header name each provider sends its
+// signature/token in, so handleWebhook can pass the right value to
+// source.VerifyWebhook.
+func webhookSignatureHeader(provider string) string {
+	switch provider {
+	case "gitlab":
+		return "X-Gitlab-Token"
+	case "gitea":
+		return "X-Gitea-Signature"
+	default:
+		return "X-Hub-Signature-256"
+	}
+}
 
-	return hmac.Equal([]byte(signature), []byte(expectedSignature))
+// webhookEventTypeHeader returns the header naming which kind of event a
+// webhook payload carries, so handleWebhook can react to merged pull
+// requests in addition to pushes. Only GitHub's API is wired up to check
+// which files a pull request touched today, but the header varies by
+// provider regardless.
+func webhookEventTypeHeader(provider string) string {
+	switch provider {
+	case "gitlab":
+		return "X-Gitlab-Event"
+	case "gitea":
+		return "X-Gitea-Event"
+	default:
+		return "X-GitHub-Event"
+	}
+}
+
+// pullRequestFileChecker is implemented by repo.Source backends that can
+// report which files a pull request touched. Only GitHubClient does
+// today; other providers' pull_request-equivalent events are ignored.
+type pullRequestFileChecker interface {
+	PullRequestTouchesFile(number int, filePath string) (bool, error)
 }
 
 func handleWebhook(w http.ResponseWriter, r *http.Request, config *Config) {
@@ -85,13 +154,25 @@ func handleWebhook(w http.ResponseWriter, r *http.Request, config *Config) {
 		return
 	}
 
-	signature := r.Header.Get("X-Hub-Signature-256")
-	if !verifyWebhookSignature(payload, signature, config.WebhookSecret) {
+	source, err := newSource(config)
+	if err != nil {
+		log.Printf("Error building git source: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	signature := r.Header.Get(webhookSignatureHeader(config.GitProvider))
+	if !source.VerifyWebhook(payload, signature) {
 		log.Printf("Invalid webhook signature")
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
+	if eventType := r.Header.Get(webhookEventTypeHeader(config.GitProvider)); eventType == "pull_request" {
+		handlePullRequestWebhook(w, payload, config, source)
+		return
+	}
+
 	var webhook GitHubWebhook
 	if err := json.Unmarshal(payload, &webhook); err != nil {
 		log.Printf("Error parsing webhook payload: %v", err)
@@ -127,7 +208,65 @@ func handleWebhook(w http.ResponseWriter, r *http.Request, config *Config) {
 	}
 
 	log.Printf("track-versions.yaml modified, processing upgrade...")
-	
+
+	if err := processUpgrade(config); err != nil {
+		log.Printf("Upgrade failed: %v", err)
+		http.Error(w, "Upgrade failed", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "Upgrade processed successfully")
+}
+
+// handlePullRequestWebhook reacts to a pull request merged into main that
+// touched track-versions.yaml, so the source of truth for an upgrade can
+// be a reviewed PR (e.g. a version bump, or a revert of one) rather than
+// only a direct push. The PRs ProposeVersionUpdate opens touch only
+// applied-versions.yaml and are ignored here.
+func handlePullRequestWebhook(w http.ResponseWriter, payload []byte, config *Config, source repo.Source) {
+	var webhook struct {
+		Action      string `json:"action"`
+		PullRequest struct {
+			Number int  `json:"number"`
+			Merged bool `json:"merged"`
+			Base   struct {
+				Ref string `json:"ref"`
+			} `json:"base"`
+		} `json:"pull_request"`
+	}
+	if err := json.Unmarshal(payload, &webhook); err != nil {
+		log.Printf("Error parsing pull_request webhook payload: %v", err)
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	if webhook.Action != "closed" || !webhook.PullRequest.Merged || webhook.PullRequest.Base.Ref != "main" {
+		log.Printf("Ignoring pull_request event (action=%s, merged=%t, base=%s)", webhook.Action, webhook.PullRequest.Merged, webhook.PullRequest.Base.Ref)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	checker, ok := source.(pullRequestFileChecker)
+	if !ok {
+		log.Printf("Git provider %s cannot check which files a pull request touched, ignoring pull_request event", config.GitProvider)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	touchesVersions, err := checker.PullRequestTouchesFile(webhook.PullRequest.Number, "infrastructure/cluster/track-versions.yaml")
+	if err != nil {
+		log.Printf("Error checking pull request %d files: %v", webhook.PullRequest.Number, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !touchesVersions {
+		log.Printf("Merged pull request %d did not touch track-versions.yaml, ignoring", webhook.PullRequest.Number)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	log.Printf("Merged pull request %d touched track-versions.yaml, processing upgrade...", webhook.PullRequest.Number)
 	if err := processUpgrade(config); err != nil {
 		log.Printf("Upgrade failed: %v", err)
 		http.Error(w, "Upgrade failed", http.StatusInternalServerError)
@@ -140,10 +279,13 @@ func handleWebhook(w http.ResponseWriter, r *http.Request, config *Config) {
 
 func processUpgradeWithTestOverrides(cfg *Config, currentK8s, currentTalos, scenario string) error {
 	log.Printf("Fetching current versions from repository...")
-	
-	// Create GitHub client and fetch versions
-	githubClient := repo.NewGitHubClient(cfg.GitHubToken)
-	versions, err := githubClient.FetchVersions(cfg.GitHubOwner, cfg.GitHubRepo)
+
+	// Build the configured git source and fetch versions
+	source, err := newSource(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to build git source: %w", err)
+	}
+	versions, err := source.FetchVersions()
 	if err != nil {
 		return fmt.Errorf("failed to fetch versions: %w", err)
 	}
@@ -160,7 +302,7 @@ func processUpgradeWithTestOverrides(cfg *Config, currentK8s, currentTalos, scen
 	if err != nil {
 		return fmt.Errorf("failed to get cluster nodes: %w", err)
 	}
-	
+
 	controlPlaneNode, err := talosConfig.GetFirstControlPlaneNode()
 	if err != nil {
 		return fmt.Errorf("failed to get control plane node: %w", err)
@@ -171,8 +313,8 @@ func processUpgradeWithTestOverrides(cfg *Config, currentK8s, currentTalos, scen
 
 	// Step 1: Upgrade Talos if needed (must be done first)
 	log.Printf("Checking for Talos upgrade...")
-	talosUpgrader := upgrades.NewTalosUpgrader(cfg.TalosConfigPath, cfg.LogPath, githubClient)
-	
+	talosUpgrader := upgrades.NewTalosUpgrader(cfg.TalosConfigPath, cfg.LogPath, source)
+
 	// Apply test overrides for Talos
 	if currentTalos != "" {
 		talosUpgrader.SetMockCurrentVersion(currentTalos)
@@ -184,8 +326,8 @@ func processUpgradeWithTestOverrides(cfg *Config, currentK8s, currentTalos, scen
 			log.Printf("TEST SCENARIO: Using Talos version 1.10.5")
 		}
 	}
-	
-	if err := talosUpgrader.UpgradeToVersion(versions.TalosVersion, allNodes, cfg.GitHubOwner, cfg.GitHubRepo, false); err != nil {
+
+	if err := talosUpgrader.UpgradeToVersion(versions.TalosVersion, allNodes, false); err != nil {
 		return fmt.Errorf("talos upgrade failed: %w", err)
 	}
 
@@ -194,7 +336,7 @@ func processUpgradeWithTestOverrides(cfg *Config, currentK8s, currentTalos, scen
 	// Step 2: Upgrade Kubernetes if needed
 	log.Printf("Starting Kubernetes upgrade...")
 	k8sUpgrader := upgrades.NewKubernetesUpgrader(cfg.TalosConfigPath, cfg.LogPath)
-	
+
 	// Apply test overrides for K8s
 	if currentK8s != "" {
 		k8sUpgrader.SetMockCurrentVersion(currentK8s)
@@ -206,7 +348,7 @@ func processUpgradeWithTestOverrides(cfg *Config, currentK8s, currentTalos, scen
 			log.Printf("TEST SCENARIO: Using K8s version 1.33.2")
 		}
 	}
-	
+
 	if err := k8sUpgrader.UpgradeToVersion(versions.KubernetesVersion, controlPlaneNode, false); err != nil {
 		return fmt.Errorf("kubernetes upgrade failed: %w", err)
 	}
@@ -217,15 +359,18 @@ func processUpgradeWithTestOverrides(cfg *Config, currentK8s, currentTalos, scen
 
 func processUpgrade(cfg *Config) error {
 	log.Printf("Fetching current versions from repository...")
-	
-	// Create GitHub client and fetch versions
-	githubClient := repo.NewGitHubClient(cfg.GitHubToken)
-	versions, err := githubClient.FetchVersions(cfg.GitHubOwner, cfg.GitHubRepo)
+
+	// Build the configured git source and fetch versions
+	source, err := newSource(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to build git source: %w", err)
+	}
+	versions, err := source.FetchVersions()
 	if err != nil {
 		return fmt.Errorf("failed to fetch versions: %w", err)
 	}
 
-	log.Printf("Found versions - Talos: %s, Kubernetes: %s", versions.TalosVersion, versions.KubernetesVersion)
+	log.Printf("Found versions - Talos: %s, Kubernetes: %s, Kubelet: %s", versions.TalosVersion, versions.KubernetesVersion, versions.KubeletVersion)
 
 	// Parse talos config to get node information
 	talosConfig, err := talos.ParseConfig(cfg.TalosConfigPath)
@@ -237,7 +382,7 @@ func processUpgrade(cfg *Config) error {
 	if err != nil {
 		return fmt.Errorf("failed to get cluster nodes: %w", err)
 	}
-	
+
 	controlPlaneNode, err := talosConfig.GetFirstControlPlaneNode()
 	if err != nil {
 		return fmt.Errorf("failed to get control plane node: %w", err)
@@ -246,11 +391,38 @@ func processUpgrade(cfg *Config) error {
 	log.Printf("Using control plane node: %s", controlPlaneNode)
 	log.Printf("Cluster has %d nodes: %v", len(allNodes), allNodes)
 
+	k8sUpgrader := upgrades.NewKubernetesUpgrader(cfg.TalosConfigPath, cfg.LogPath)
+	k8sUpgrader.ForceSkipSkew = cfg.ForceSkipSkew
+
+	if isKubeletOnlyChange(cfg.LogPath, versions) {
+		log.Printf("Only kubeletVersion changed since the last processed versions, running kubelet-only upgrade")
+		if err := k8sUpgrader.UpgradeKubeletOnly(versions.KubeletVersion, allNodes, true); err != nil {
+			return fmt.Errorf("kubelet-only upgrade failed: %w", err)
+		}
+		return saveLastVersions(cfg.LogPath, versions)
+	}
+
+	controlPlaneNodes, err := talosConfig.GetControlPlaneNodes()
+	if err != nil {
+		return fmt.Errorf("failed to get control plane nodes: %w", err)
+	}
+
+	talosUpgrader := upgrades.NewTalosUpgrader(cfg.TalosConfigPath, cfg.LogPath, source)
+	talosUpgrader.ControlPlaneNodes = controlPlaneNodes
+	talosUpgrader.MaxUnavailable = cfg.MaxUnavailable
+	talosUpgrader.ForceSkipSkew = cfg.ForceSkipSkew
+	talosUpgrader.CosignPubKey = cfg.CosignPubKey
+	talosUpgrader.AllowSchematicChange = cfg.AllowSchematicChange
+
+	log.Printf("Running compatibility check ahead of any real upgrade...")
+	if err := checkCompatibility(talosUpgrader, k8sUpgrader, controlPlaneNode, versions, cfg.ForceSkipSkew); err != nil {
+		return err
+	}
+
 	// Step 1: Upgrade Talos if needed (must be done first)
 	log.Printf("Checking for Talos upgrade...")
-	talosUpgrader := upgrades.NewTalosUpgrader(cfg.TalosConfigPath, cfg.LogPath, githubClient)
-	
-	if err := talosUpgrader.UpgradeToVersion(versions.TalosVersion, allNodes, cfg.GitHubOwner, cfg.GitHubRepo, true); err != nil {
+
+	if err := talosUpgrader.UpgradeToVersion(versions.TalosVersion, allNodes, true); err != nil {
 		return fmt.Errorf("talos upgrade failed: %w", err)
 	}
 
@@ -258,16 +430,398 @@ func processUpgrade(cfg *Config) error {
 
 	// Step 2: Upgrade Kubernetes if needed
 	log.Printf("Starting Kubernetes upgrade...")
-	k8sUpgrader := upgrades.NewKubernetesUpgrader(cfg.TalosConfigPath, cfg.LogPath)
-	
+
 	if err := k8sUpgrader.UpgradeToVersion(versions.KubernetesVersion, controlPlaneNode, true); err != nil {
 		return fmt.Errorf("kubernetes upgrade failed: %w", err)
 	}
 
 	log.Printf("All upgrades completed successfully")
+
+	proposeAppliedVersionUpdate(source, talosUpgrader, k8sUpgrader, cfg.LogPath, controlPlaneNode, allNodes)
+
+	return saveLastVersions(cfg.LogPath, versions)
+}
+
+// versionProposer is implemented by repo.Source backends that can open a
+// pull request recording what's actually running on the cluster. Only
+// GitHubClient does today; other providers skip this step.
+type versionProposer interface {
+	ProposeVersionUpdate(content []byte, logTail string) (string, error)
+}
+
+// appliedVersions is the companion record ProposeVersionUpdate commits to
+// infrastructure/cluster/applied-versions.yaml: what's actually running,
+// as observed live, rather than what track-versions.yaml merely requests.
+type appliedVersions struct {
+	TalosVersion      string            `yaml:"talosVersion"`
+	KubernetesVersion string            `yaml:"kubernetesVersion"`
+	KubeletVersions   map[string]string `yaml:"kubeletVersions"`
+	UpdatedAt         string            `yaml:"updatedAt"`
+}
+
+// proposeAppliedVersionUpdate opens a pull request recording the
+// versions actually running on the cluster after a successful upgrade,
+// if the configured git provider supports it. Failures here are logged
+// but never fail the upgrade itself, since the upgrade has already
+// succeeded by the time this runs.
+func proposeAppliedVersionUpdate(source repo.Source, talosUpgrader *upgrades.TalosUpgrader, k8sUpgrader *upgrades.KubernetesUpgrader, logPath, controlPlaneNode string, allNodes []string) {
+	proposer, ok := source.(versionProposer)
+	if !ok {
+		return
+	}
+
+	content, err := buildAppliedVersions(talosUpgrader, k8sUpgrader, controlPlaneNode, allNodes)
+	if err != nil {
+		log.Printf("WARNING: failed to build applied-versions content, skipping pull request: %v", err)
+		return
+	}
+
+	logTail, err := readLogTail(logPath)
+	if err != nil {
+		log.Printf("WARNING: failed to read upgrade log tail: %v", err)
+	}
+
+	prURL, err := proposer.ProposeVersionUpdate(content, logTail)
+	if err != nil {
+		log.Printf("WARNING: failed to open applied-versions pull request: %v", err)
+		return
+	}
+
+	log.Printf("Opened pull request recording applied versions: %s", prURL)
+}
+
+// buildAppliedVersions queries the cluster directly (not track-versions.yaml)
+// for the Talos and Kubernetes versions actually running, plus each
+// node's kubelet version, so applied-versions.yaml reflects ground truth.
+func buildAppliedVersions(talosUpgrader *upgrades.TalosUpgrader, k8sUpgrader *upgrades.KubernetesUpgrader, controlPlaneNode string, allNodes []string) ([]byte, error) {
+	talosVersion, err := talosUpgrader.GetCurrentVersion(true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current Talos version: %w", err)
+	}
+	kubernetesVersion, err := k8sUpgrader.GetCurrentVersion(controlPlaneNode, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current Kubernetes version: %w", err)
+	}
+
+	kubeletVersions := make(map[string]string, len(allNodes))
+	for _, node := range allNodes {
+		version, err := k8sUpgrader.GetNodeKubeletVersion(node)
+		if err != nil {
+			log.Printf("WARNING: failed to get kubelet version for node %s: %v", node, err)
+			continue
+		}
+		kubeletVersions[node] = version
+	}
+
+	applied := appliedVersions{
+		TalosVersion:      talosVersion,
+		KubernetesVersion: kubernetesVersion,
+		KubeletVersions:   kubeletVersions,
+		UpdatedAt:         time.Now().Format(time.RFC3339),
+	}
+
+	data, err := yaml.Marshal(applied)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal applied versions: %w", err)
+	}
+	return data, nil
+}
+
+// logTailMaxBytes bounds how much of the most recently modified upgrade
+// log file gets attached to a ProposeVersionUpdate pull request body.
+const logTailMaxBytes = 8192
+
+// readLogTail returns the tail of the most recently modified *.log file
+// under logPath.
+func readLogTail(logPath string) (string, error) {
+	entries, err := os.ReadDir(logPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to list log directory: %w", err)
+	}
+
+	var newestName string
+	var newestModTime time.Time
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".log") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if newestName == "" || info.ModTime().After(newestModTime) {
+			newestName = entry.Name()
+			newestModTime = info.ModTime()
+		}
+	}
+	if newestName == "" {
+		return "", nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(logPath, newestName))
+	if err != nil {
+		return "", fmt.Errorf("failed to read log file %s: %w", newestName, err)
+	}
+	if len(data) > logTailMaxBytes {
+		data = data[len(data)-logTailMaxBytes:]
+	}
+	return string(data), nil
+}
+
+// checkCompatibility fetches the real current Talos/Kubernetes versions
+// and runs them through the shared compatibility policy before any real
+// upgrade command is invoked.
+func checkCompatibility(talosUpgrader *upgrades.TalosUpgrader, k8sUpgrader *upgrades.KubernetesUpgrader, controlPlaneNode string, versions *repo.Versions, forceSkipSkew bool) error {
+	currentTalos, err := talosUpgrader.GetCurrentVersion(true)
+	if err != nil {
+		return fmt.Errorf("compatibility check: cannot determine current Talos version: %w", err)
+	}
+	currentKubernetes, err := k8sUpgrader.GetCurrentVersion(controlPlaneNode, true)
+	if err != nil {
+		return fmt.Errorf("compatibility check: cannot determine current Kubernetes version: %w", err)
+	}
+
+	result, err := compatibility.CheckUpgrade(currentTalos, currentKubernetes, versions.TalosVersion, versions.KubernetesVersion, versions.AllowDowngrade, forceSkipSkew)
+	if err != nil {
+		return fmt.Errorf("compatibility check failed: %w", err)
+	}
+	if !result.Allowed {
+		return result.Error()
+	}
+
+	log.Printf("Compatibility check passed: %s/%s -> %s/%s", currentTalos, currentKubernetes, versions.TalosVersion, versions.KubernetesVersion)
+	return nil
+}
+
+// lastVersionsFile records the repo.Versions that were last
+// successfully processed, so processUpgrade can recognize a kubelet-only
+// diff on track-versions.yaml and skip a full control-plane cycle.
+const lastVersionsFile = "last-processed-versions.json"
+
+func lastVersionsPath(logPath string) string {
+	return fmt.Sprintf("%s/%s", logPath, lastVersionsFile)
+}
+
+func loadLastVersions(logPath string) (*repo.Versions, error) {
+	data, err := os.ReadFile(lastVersionsPath(logPath))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read last-processed versions: %w", err)
+	}
+	var v repo.Versions
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("failed to parse last-processed versions: %w", err)
+	}
+	return &v, nil
+}
+
+func saveLastVersions(logPath string, v *repo.Versions) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal last-processed versions: %w", err)
+	}
+	if err := os.WriteFile(lastVersionsPath(logPath), data, 0o644); err != nil {
+		return fmt.Errorf("failed to write last-processed versions: %w", err)
+	}
 	return nil
 }
 
+// isKubeletOnlyChange reports whether versions differs from the last
+// successfully processed versions only in KubeletVersion.
+func isKubeletOnlyChange(logPath string, versions *repo.Versions) bool {
+	last, err := loadLastVersions(logPath)
+	if err != nil {
+		log.Printf("WARNING: failed to load last-processed versions: %v", err)
+		return false
+	}
+	if last == nil || versions.KubeletVersion == "" {
+		return false
+	}
+	return last.TalosVersion == versions.TalosVersion &&
+		last.KubernetesVersion == versions.KubernetesVersion &&
+		last.KubeletVersion != versions.KubeletVersion
+}
+
+// planEndpoint exposes a read-only "upgrade plan" view, similar to
+// `kubeadm upgrade plan`: it reports current versions, what's available
+// upstream, and whether a requested to_talos/to_kubernetes target is
+// safe, without ever
+// invoking UpgradeToVersion.
+func planEndpoint(w http.ResponseWriter, r *http.Request, config *Config) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	authHeader := r.Header.Get("Authorization")
+	token := strings.TrimPrefix(authHeader, "Bearer ")
+	if !strings.HasPrefix(authHeader, "Bearer ") || token != config.DiagnosticsToken {
+		log.Printf("Plan endpoint accessed with invalid or missing Authorization header")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	toTalos := r.URL.Query().Get("to_talos")
+	toKubernetes := r.URL.Query().Get("to_kubernetes")
+
+	source, err := newSource(config)
+	if err != nil {
+		log.Printf("Failed to build git source: %v", err)
+		http.Error(w, fmt.Sprintf("failed to build git source: %v", err), http.StatusInternalServerError)
+		return
+	}
+	planner := upgrades.NewUpgradePlanner(config.TalosConfigPath, config.LogPath, source)
+
+	plan, err := planner.Plan(toTalos, toKubernetes)
+	if err != nil {
+		log.Printf("Failed to build upgrade plan: %v", err)
+		http.Error(w, fmt.Sprintf("failed to build upgrade plan: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, plan.String())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(plan)
+}
+
+// upgradeStatusEndpoint exposes the last recorded Talos upgrade journal
+// entry, so an operator can check "what's the last upgrade state?" without
+// grepping log files. The Kubernetes upgrade journal is not included.
+func upgradeStatusEndpoint(w http.ResponseWriter, r *http.Request, config *Config) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	authHeader := r.Header.Get("Authorization")
+	token := strings.TrimPrefix(authHeader, "Bearer ")
+	if !strings.HasPrefix(authHeader, "Bearer ") || token != config.DiagnosticsToken {
+		log.Printf("Upgrade status endpoint accessed with invalid or missing Authorization header")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	source, err := newSource(config)
+	if err != nil {
+		log.Printf("Failed to build git source: %v", err)
+		http.Error(w, fmt.Sprintf("failed to build git source: %v", err), http.StatusInternalServerError)
+		return
+	}
+	talosUpgrader := upgrades.NewTalosUpgrader(config.TalosConfigPath, config.LogPath, source)
+	status, err := talosUpgrader.JournalStatus()
+	if err != nil {
+		log.Printf("Failed to read upgrade journal: %v", err)
+		http.Error(w, fmt.Sprintf("failed to read upgrade journal: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// upgradeComponentsEndpoint triggers a component-scoped Kubernetes
+// upgrade (control plane only, kubelet only, or both) via
+// KubernetesUpgrader.UpgradeComponents, for operators who want to bump
+// one component ahead of the other without waiting for the next
+// full-cluster upgrade cycle.
+func upgradeComponentsEndpoint(w http.ResponseWriter, r *http.Request, config *Config) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	authHeader := r.Header.Get("Authorization")
+	token := strings.TrimPrefix(authHeader, "Bearer ")
+	if !strings.HasPrefix(authHeader, "Bearer ") || token != config.DiagnosticsToken {
+		log.Printf("Upgrade components endpoint accessed with invalid or missing Authorization header")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	version := r.URL.Query().Get("version")
+	if version == "" {
+		http.Error(w, "version query parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	var components upgrades.Components
+	switch r.URL.Query().Get("component") {
+	case "control-plane":
+		components = upgrades.Components{ControlPlane: true}
+	case "kubelet":
+		components = upgrades.Components{Kubelet: true}
+	case "", "both":
+		components = upgrades.AllComponents
+	default:
+		http.Error(w, "component must be one of: control-plane, kubelet, both", http.StatusBadRequest)
+		return
+	}
+
+	talosConfig, err := talos.ParseConfig(config.TalosConfigPath)
+	if err != nil {
+		log.Printf("Failed to parse talos config: %v", err)
+		http.Error(w, fmt.Sprintf("failed to parse talos config: %v", err), http.StatusInternalServerError)
+		return
+	}
+	node := r.URL.Query().Get("node")
+	if node == "" {
+		node, err = talosConfig.GetFirstControlPlaneNode()
+		if err != nil {
+			log.Printf("Failed to get control plane node: %v", err)
+			http.Error(w, fmt.Sprintf("failed to get control plane node: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	k8sUpgrader := upgrades.NewKubernetesUpgrader(config.TalosConfigPath, config.LogPath)
+	k8sUpgrader.ForceSkipSkew = config.ForceSkipSkew
+
+	if err := k8sUpgrader.UpgradeComponents(version, node, components, true); err != nil {
+		log.Printf("Component-scoped upgrade failed: %v", err)
+		http.Error(w, fmt.Sprintf("component-scoped upgrade failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "completed", "version": version, "node": node})
+}
+
+// rolloutStatusEndpoint exposes the current per-node progressive rollout
+// state (cordon/drain/upgrade/health-gate/uncordon progress), distinct
+// from upgradeStatusEndpoint's coarser "did the upgrade command succeed"
+// view.
+func rolloutStatusEndpoint(w http.ResponseWriter, r *http.Request, config *Config) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	authHeader := r.Header.Get("Authorization")
+	token := strings.TrimPrefix(authHeader, "Bearer ")
+	if !strings.HasPrefix(authHeader, "Bearer ") || token != config.DiagnosticsToken {
+		log.Printf("Rollout status endpoint accessed with invalid or missing Authorization header")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	status, err := upgrades.RolloutStatus(config.LogPath)
+	if err != nil {
+		log.Printf("Failed to read rollout state: %v", err)
+		http.Error(w, fmt.Sprintf("failed to read rollout state: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
 func healthCheck(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	fmt.Fprint(w, "OK")
@@ -303,18 +857,23 @@ func diagnosticsEndpoint(w http.ResponseWriter, r *http.Request, config *Config)
 	log.Printf("Diagnostics endpoint accessed with valid token")
 
 	w.Header().Set("Content-Type", "application/json")
-	
+
 	// Check for scenario parameter to simulate different conditions
 	scenario := r.URL.Query().Get("scenario")
 	currentK8s := r.URL.Query().Get("current_k8s")
 	currentTalos := r.URL.Query().Get("current_talos")
-	
+
 	results := make(map[string]interface{})
-	
-	// Test 1: GitHub API
-	log.Printf("Testing GitHub API connection...")
-	githubClient := repo.NewGitHubClient(config.GitHubToken)
-	versions, err := githubClient.FetchVersions(config.GitHubOwner, config.GitHubRepo)
+
+	// Test 1: Git source API
+	log.Printf("Testing git source connection...")
+	source, err := newSource(config)
+	if err != nil {
+		log.Printf("Failed to build git source: %v", err)
+		http.Error(w, fmt.Sprintf("failed to build git source: %v", err), http.StatusInternalServerError)
+		return
+	}
+	versions, err := source.FetchVersions()
 	if err != nil {
 		results["github_api"] = map[string]interface{}{
 			"status": "failed",
@@ -349,7 +908,7 @@ func diagnosticsEndpoint(w http.ResponseWriter, r *http.Request, config *Config)
 
 	// Test 3: Bare Metal Config
 	log.Printf("Testing bare metal config fetch...")
-	bareMetalConfig, err := githubClient.FetchBareMetalConfig(config.GitHubOwner, config.GitHubRepo)
+	bareMetalConfig, err := source.FetchBareMetalConfig()
 	if err != nil {
 		results["bare_metal_config"] = map[string]interface{}{
 			"status": "failed",
@@ -374,7 +933,7 @@ func diagnosticsEndpoint(w http.ResponseWriter, r *http.Request, config *Config)
 			}
 		} else {
 			results["image_factory"] = map[string]interface{}{
-				"status":      "success",
+				"status":       "success",
 				"schematic_id": schematicID,
 			}
 		}
@@ -388,33 +947,46 @@ func diagnosticsEndpoint(w http.ResponseWriter, r *http.Request, config *Config)
 	// Test 5: Run the exact same upgrade logic as production, but with test mode
 	if talosConfig != nil {
 		log.Printf("Running IDENTICAL upgrade logic as production...")
-		
+
 		// Get real versions first for logging comparison
 		tempK8sUpgrader := upgrades.NewKubernetesUpgrader(config.TalosConfigPath, config.LogPath)
-		tempTalosUpgrader := upgrades.NewTalosUpgrader(config.TalosConfigPath, config.LogPath, githubClient)
+		tempTalosUpgrader := upgrades.NewTalosUpgrader(config.TalosConfigPath, config.LogPath, source)
 		controlPlaneNode, _ := talosConfig.GetFirstControlPlaneNode()
-		
+
 		realK8sVersion, k8sErr := tempK8sUpgrader.GetCurrentVersion(controlPlaneNode, true)
 		realTalosVersion, talosErr := tempTalosUpgrader.GetCurrentVersion(true)
-		
+
 		if k8sErr == nil {
 			log.Printf("Detected K8s version: %s", realK8sVersion)
 		} else {
 			log.Printf("Could not get real K8s version: %v", k8sErr)
 		}
-		
+
 		if talosErr == nil {
 			log.Printf("Detected Talos version: %s", realTalosVersion)
 		} else {
 			log.Printf("Could not get real Talos version: %v", talosErr)
 		}
-		
+
+		realKubeletVersions := map[string]string{}
+		if allNodes, nodesErr := talosConfig.GetAllNodes(); nodesErr == nil {
+			for _, node := range allNodes {
+				kubeletVersion, kubeletErr := tempK8sUpgrader.GetNodeKubeletVersion(node)
+				if kubeletErr != nil {
+					log.Printf("Could not get real kubelet version for node %s: %v", node, kubeletErr)
+					continue
+				}
+				realKubeletVersions[node] = kubeletVersion
+			}
+		}
+
 		results["cluster_versions"] = map[string]interface{}{
 			"status":               "success",
 			"real_k8s_version":     realK8sVersion,
 			"real_talos_version":   realTalosVersion,
+			"real_kubelet_version": realKubeletVersions,
 		}
-		
+
 		// Make overrides explicit in logs if provided
 		if currentK8s != "" && realK8sVersion != "" && currentK8s != realK8sVersion {
 			log.Printf("Detected K8s version %s, overriding with %s from diagnostics call", realK8sVersion, currentK8s)
@@ -422,7 +994,25 @@ func diagnosticsEndpoint(w http.ResponseWriter, r *http.Request, config *Config)
 		if currentTalos != "" && realTalosVersion != "" && currentTalos != realTalosVersion {
 			log.Printf("Detected Talos version %s, overriding with %s from diagnostics call", realTalosVersion, currentTalos)
 		}
-		
+
+		// Test 5.5: Compatibility check, run in dry-run form so operators
+		// can see the verdict without triggering a real upgrade.
+		if k8sErr == nil && talosErr == nil && versions != nil {
+			compatResult, err := compatibility.CheckUpgrade(realTalosVersion, realK8sVersion, versions.TalosVersion, versions.KubernetesVersion, versions.AllowDowngrade, config.ForceSkipSkew)
+			if err != nil {
+				results["compatibility"] = map[string]interface{}{
+					"status": "failed",
+					"error":  err.Error(),
+				}
+			} else {
+				results["compatibility"] = map[string]interface{}{
+					"status":  map[bool]string{true: "success", false: "failed"}[compatResult.Allowed],
+					"allowed": compatResult.Allowed,
+					"reasons": compatResult.Reasons,
+				}
+			}
+		}
+
 		// Now run the exact same processUpgrade logic with test overrides
 		err := processUpgradeWithTestOverrides(config, currentK8s, currentTalos, scenario)
 		if err != nil {
@@ -443,7 +1033,7 @@ func diagnosticsEndpoint(w http.ResponseWriter, r *http.Request, config *Config)
 		log.Printf("Testing upgrade decision logic...")
 		controlPlaneNode, _ := talosConfig.GetFirstControlPlaneNode()
 		allNodes, _ := talosConfig.GetAllNodes()
-		
+
 		results["upgrade_decisions"] = map[string]interface{}{
 			"would_upgrade_kubernetes": versions.KubernetesVersion,
 			"would_upgrade_talos":      versions.TalosVersion,
@@ -455,7 +1045,7 @@ func diagnosticsEndpoint(w http.ResponseWriter, r *http.Request, config *Config)
 	// Summary
 	results["summary"] = map[string]interface{}{
 		"timestamp":     fmt.Sprintf("%d", time.Now().Unix()),
-		"ready":         checkAllTestsPass(results),
+		"ready":         checkAllTestsPass(results, nil),
 		"scenario":      scenario,
 		"current_k8s":   currentK8s,
 		"current_talos": currentTalos,
@@ -464,9 +1054,14 @@ func diagnosticsEndpoint(w http.ResponseWriter, r *http.Request, config *Config)
 	json.NewEncoder(w).Encode(results)
 }
 
-func checkAllTestsPass(results map[string]interface{}) bool {
-	tests := []string{"github_api", "talos_config", "bare_metal_config", "image_factory", "cluster_versions", "k8s_upgrade_test", "talos_upgrade_test"}
-	
+// checkAllTestsPass reports whether every diagnostics test in results
+// succeeded. When scenarioReport is non-nil, its pass/fail verdict is
+// folded in too, so a partial scenario run (some cases erroring, not all
+// scenarios executed) still yields a meaningful ready/not-ready status
+// rather than silently passing.
+func checkAllTestsPass(results map[string]interface{}, scenarioReport *scenarios.Report) bool {
+	tests := []string{"github_api", "talos_config", "bare_metal_config", "image_factory", "cluster_versions", "compatibility", "upgrade_test", "k8s_upgrade_test", "talos_upgrade_test"}
+
 	for _, test := range tests {
 		if testResult, exists := results[test]; exists {
 			if resultMap, ok := testResult.(map[string]interface{}); ok {
@@ -478,9 +1073,48 @@ func checkAllTestsPass(results map[string]interface{}) bool {
 			}
 		}
 	}
+
+	if scenarioReport != nil && !scenarioReport.Ready() {
+		return false
+	}
+
 	return true
 }
 
+// scenariosEndpoint runs the declarative scenario matrix (loaded from
+// config.ScenariosPath) against the real compatibility policy and returns
+// a JUnit-style report. It never invokes UpgradeToVersion, so it's safe
+// to hit against a live cluster's config.
+func scenariosEndpoint(w http.ResponseWriter, r *http.Request, config *Config) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	authHeader := r.Header.Get("Authorization")
+	token := strings.TrimPrefix(authHeader, "Bearer ")
+	if !strings.HasPrefix(authHeader, "Bearer ") || token != config.DiagnosticsToken {
+		log.Printf("Scenarios endpoint accessed with invalid or missing Authorization header")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	scns, err := scenarios.Load(config.ScenariosPath)
+	if err != nil {
+		log.Printf("Failed to load scenarios: %v", err)
+		http.Error(w, fmt.Sprintf("failed to load scenarios: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	report := scenarios.RunMatrix(scns)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"report": report,
+		"ready":  checkAllTestsPass(map[string]interface{}{}, report),
+	})
+}
+
 func main() {
 	config := loadConfig()
 
@@ -488,14 +1122,25 @@ func main() {
 	if config.WebhookSecret == "" {
 		log.Fatal("GITHUB_WEBHOOK_SECRET environment variable is required")
 	}
-	if config.GitHubToken == "" {
-		log.Fatal("GITHUB_TOKEN environment variable is required")
-	}
-	if config.GitHubOwner == "" {
-		log.Fatal("GITHUB_OWNER environment variable is required")
+	switch config.GitProvider {
+	case "", "github", "gitea":
+		if config.GitHubOwner == "" {
+			log.Fatal("GITHUB_OWNER environment variable is required")
+		}
+		if config.GitHubRepo == "" {
+			log.Fatal("GITHUB_REPO environment variable is required")
+		}
+	case "gitlab":
+		if config.GitHubRepo == "" {
+			log.Fatal("GITHUB_REPO environment variable is required (used as the GitLab project path)")
+		}
+	case "gitclone":
+		if config.GitURL == "" {
+			log.Fatal("GIT_URL environment variable is required")
+		}
 	}
-	if config.GitHubRepo == "" {
-		log.Fatal("GITHUB_REPO environment variable is required")
+	if config.GitProvider != "gitclone" && config.GitHubToken == "" {
+		log.Fatal("GITHUB_TOKEN environment variable is required")
 	}
 	if config.TalosConfigPath == "" {
 		log.Fatal("TALOS_CONFIG_PATH environment variable is required")
@@ -510,13 +1155,33 @@ func main() {
 	http.HandleFunc("/webhook", func(w http.ResponseWriter, r *http.Request) {
 		handleWebhook(w, r, config)
 	})
-	
+
 	http.HandleFunc("/health", healthCheck)
-	
+
 	http.HandleFunc("/diagnostics", func(w http.ResponseWriter, r *http.Request) {
 		diagnosticsEndpoint(w, r, config)
 	})
 
+	http.HandleFunc("/diagnostics/scenarios", func(w http.ResponseWriter, r *http.Request) {
+		scenariosEndpoint(w, r, config)
+	})
+
+	http.HandleFunc("/plan", func(w http.ResponseWriter, r *http.Request) {
+		planEndpoint(w, r, config)
+	})
+
+	http.HandleFunc("/upgrade/status", func(w http.ResponseWriter, r *http.Request) {
+		upgradeStatusEndpoint(w, r, config)
+	})
+
+	http.HandleFunc("/upgrade/components", func(w http.ResponseWriter, r *http.Request) {
+		upgradeComponentsEndpoint(w, r, config)
+	})
+
+	http.HandleFunc("/rollout/status", func(w http.ResponseWriter, r *http.Request) {
+		rolloutStatusEndpoint(w, r, config)
+	})
+
 	log.Printf("Starting talos-automation webhook server on port %s", config.Port)
 	log.Fatal(http.ListenAndServe(":"+config.Port, nil))
 }
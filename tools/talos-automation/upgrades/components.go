@@ -0,0 +1,137 @@
+package upgrades
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"talos-automation/internal/semver"
+)
+
+// Components restricts which parts of the Kubernetes control plane a
+// given upgrade touches, mirroring talosctl upgrade-k8s's per-component
+// flags. The zero value upgrades nothing; use AllComponents for the
+// default full-cluster behavior.
+type Components struct {
+	ControlPlane bool
+	Kubelet      bool
+}
+
+// AllComponents upgrades both the control plane and kubelet, matching the
+// historical UpgradeToVersion behavior.
+var AllComponents = Components{ControlPlane: true, Kubelet: true}
+
+// kubeletMaxMinorsBehind is the maximum number of minor versions kubelet
+// may lag behind the control plane, per Kubernetes' version skew policy.
+const kubeletMaxMinorsBehind = 3
+
+// kubeComponentImages is the registry.k8s.io image for each control-plane
+// static pod talosctl upgrade-k8s can pin independently of kubelet.
+var kubeComponentImages = map[string]string{
+	"apiserver-image":          "registry.k8s.io/kube-apiserver",
+	"controller-manager-image": "registry.k8s.io/kube-controller-manager",
+	"scheduler-image":          "registry.k8s.io/kube-scheduler",
+}
+
+// talosctlArgs translates Components into the talosctl upgrade-k8s flags
+// that scope the upgrade accordingly. talosctl has no flag to leave the
+// control plane alone outright, so when ControlPlane is false the three
+// control-plane images are pinned to currentVersion instead, letting
+// kubelet move on its own.
+func (c Components) talosctlArgs(currentVersion string) []string {
+	if c.ControlPlane && c.Kubelet {
+		return nil
+	}
+
+	args := []string{fmt.Sprintf("--upgrade-kubelet=%t", c.Kubelet)}
+	if !c.ControlPlane {
+		for _, flag := range []string{"apiserver-image", "controller-manager-image", "scheduler-image"} {
+			args = append(args, fmt.Sprintf("--%s=%s:v%s", flag, kubeComponentImages[flag], currentVersion))
+		}
+	}
+	return args
+}
+
+// UpgradeComponents upgrades only the requested components to version on
+// the given node, after validating that the resulting control-plane /
+// kubelet version matrix still satisfies Kubernetes' skew policy. This
+// supports bumping kubelet independently of the control plane (or vice
+// versa) without a full-cluster jump.
+func (k *KubernetesUpgrader) UpgradeComponents(version, node string, components Components, executeCommands bool) error {
+	log.Printf("=== Kubernetes Component-Scoped Upgrade Started ===")
+	log.Printf("Target version: %s, node: %s, components: %+v, execute: %t", version, node, components, executeCommands)
+
+	if !components.ControlPlane && !components.Kubelet {
+		return fmt.Errorf("no components selected for upgrade")
+	}
+
+	if !k.isValidVersion(version) {
+		return fmt.Errorf("invalid Kubernetes version format: %s", version)
+	}
+	cleanVersion := strings.TrimPrefix(version, "v")
+
+	currentVersion, err := k.GetCurrentVersion(node, executeCommands)
+	if err != nil {
+		if executeCommands {
+			return fmt.Errorf("cannot determine current Kubernetes version: %w", err)
+		}
+		log.Printf("WARNING: could not determine current Kubernetes version in test mode: %v", err)
+	} else {
+		if currentVersion == cleanVersion {
+			log.Printf("DECISION: component(s) already at version %s, no upgrade needed", cleanVersion)
+			return nil
+		}
+		if err := validateUpgradePath("Kubernetes", currentVersion, cleanVersion, k.ForceSkipSkew); err != nil {
+			return err
+		}
+	}
+
+	// The resulting matrix is whichever component isn't moving, compared
+	// against the target version of the one that is.
+	controlPlaneVersion, kubeletVersion := cleanVersion, cleanVersion
+	if !components.ControlPlane {
+		controlPlaneVersion = currentVersion
+	}
+	if !components.Kubelet {
+		kubeletVersion = currentVersion
+	}
+	if err := validateComponentSkew(controlPlaneVersion, kubeletVersion); err != nil {
+		return err
+	}
+
+	if !executeCommands {
+		log.Printf("*** DRY RUN MODE: Would upgrade components %+v to %s ***", components, cleanVersion)
+		return nil
+	}
+
+	log.Printf("Running dry-run for component-scoped upgrade...")
+	if err := k.runUpgradeCommand(cleanVersion, currentVersion, node, components, true, executeCommands); err != nil {
+		return fmt.Errorf("dry-run failed: %w", err)
+	}
+
+	log.Printf("Dry-run successful, proceeding with component-scoped upgrade...")
+	if err := k.runUpgradeCommand(cleanVersion, currentVersion, node, components, false, executeCommands); err != nil {
+		return fmt.Errorf("upgrade failed: %w", err)
+	}
+
+	log.Printf("Component-scoped upgrade to version %s completed successfully", cleanVersion)
+	return nil
+}
+
+// validateComponentSkew enforces that kubelet is never ahead of the
+// control plane, and never more than kubeletMaxMinorsBehind minors behind
+// it.
+func validateComponentSkew(controlPlaneVersion, kubeletVersion string) error {
+	distance, err := semver.MinorDistance(kubeletVersion, controlPlaneVersion)
+	if err != nil {
+		return fmt.Errorf("component skew check failed: %w", err)
+	}
+	if distance < 0 {
+		return fmt.Errorf("kubelet %s cannot be newer than control plane %s", kubeletVersion, controlPlaneVersion)
+	}
+	if distance > kubeletMaxMinorsBehind {
+		return fmt.Errorf("kubelet %s is %d minor versions behind control plane %s, exceeding the %d-minor skew policy",
+			kubeletVersion, distance, controlPlaneVersion, kubeletMaxMinorsBehind)
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package upgrades
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+
+// NodeUpgradeStatus tracks a single node's progress through an upgrade
+// attempt.
+type NodeUpgradeStatus string
+
+const (
+	NodePending    NodeUpgradeStatus = "pending"
+	NodeInProgress NodeUpgradeStatus = "in-progress"
+	NodeSucceeded  NodeUpgradeStatus = "succeeded"
+	NodeFailed     NodeUpgradeStatus = "failed"
+)
+
+// NodeState is one node's entry in a JournalEntry.
+type NodeState struct {
+	Node      string            `json:"node"`
+	Status    NodeUpgradeStatus `json:"status"`
+	LastError string            `json:"lastError,omitempty"`
+}
+
+// JournalEntry records a single upgrade attempt: what it targeted, where
+// each node got to, and the last error seen, if any.
+type JournalEntry struct {
+	AttemptID        string      `json:"attemptId"`
+	StartTime        string      `json:"startTime"`
+	TargetTalos      string      `json:"targetTalos,omitempty"`
+	TargetKubernetes string      `json:"targetKubernetes,omitempty"`
+	SchematicID      string      `json:"schematicId,omitempty"`
+	Phase            string      `json:"phase"`
+	Nodes            []NodeState `json:"nodes,omitempty"`
+	LastError        string      `json:"lastError,omitempty"`
+	Completed        bool        `json:"completed"`
+}
+
+// UpgradeJournal persists a JournalEntry to a JSON file under LogPath so
+// an interrupted rollout (e.g. a home cluster rebooting mid-upgrade) can
+// be resumed rather than restarted from scratch. Component namespaces the
+// journal file ("talos" or "kubernetes") so the two upgraders don't
+// clobber each other's state.
+type UpgradeJournal struct {
+	LogPath   string
+	Component string
+	mu        sync.Mutex
+}
+
+func NewUpgradeJournal(logPath, component string) *UpgradeJournal {
+	return &UpgradeJournal{LogPath: logPath, Component: component}
+}
+
+func (j *UpgradeJournal) path() string {
+	return filepath.Join(j.LogPath, fmt.Sprintf("%s-upgrade-journal.json", j.Component))
+}
+
+// Status returns the most recently recorded journal entry, or nil if no
+// upgrade has ever run. This is what an operator queries to answer
+// "what's the last upgrade state?" without grepping log files.
+func (j *UpgradeJournal) Status() (*JournalEntry, error) {
+	return j.load()
+}
+
+func (j *UpgradeJournal) load() (*JournalEntry, error) {
+	data, err := os.ReadFile(j.path())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upgrade journal: %w", err)
+	}
+	var entry JournalEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("failed to parse upgrade journal: %w", err)
+	}
+	return &entry, nil
+}
+
+func (j *UpgradeJournal) save(entry *JournalEntry) error {
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal upgrade journal: %w", err)
+	}
+	if err := os.WriteFile(j.path(), data, 0o644); err != nil {
+		return fmt.Errorf("failed to write upgrade journal: %w", err)
+	}
+	return nil
+}
+
+// StartOrResume returns the in-progress journal entry for this exact
+// target (targetTalos, targetKubernetes, and node set) if one exists, so
+// already-succeeded nodes are skipped on retry. Otherwise it starts a
+// fresh attempt.
+func (j *UpgradeJournal) StartOrResume(targetTalos, targetKubernetes string, nodes []string) (*JournalEntry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	existing, err := j.load()
+	if err != nil {
+		return nil, err
+	}
+
+	if existing != nil && !existing.Completed &&
+		existing.TargetTalos == targetTalos && existing.TargetKubernetes == targetKubernetes &&
+		sameNodeSet(existing.Nodes, nodes) {
+		log.Printf("Resuming upgrade attempt %s (phase: %s)", existing.AttemptID, existing.Phase)
+		return existing, nil
+	}
+
+	entry := &JournalEntry{
+		AttemptID:        time.Now().Format("20060102-150405"),
+		StartTime:        time.Now().Format(time.RFC3339),
+		TargetTalos:      targetTalos,
+		TargetKubernetes: targetKubernetes,
+		Phase:            "started",
+	}
+	for _, node := range nodes {
+		entry.Nodes = append(entry.Nodes, NodeState{Node: node, Status: NodePending})
+	}
+
+	log.Printf("Starting new upgrade attempt %s", entry.AttemptID)
+	if err := j.save(entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// Resume loads the journal entry matching attemptID, for an operator
+// explicitly retrying a known attempt.
+func (j *UpgradeJournal) Resume(attemptID string) (*JournalEntry, error) {
+	entry, err := j.load()
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil || entry.AttemptID != attemptID {
+		return nil, fmt.Errorf("no journal entry found for attempt %s", attemptID)
+	}
+	return entry, nil
+}
+
+// RecordPhase updates the entry's phase (e.g. "dry-run", "schematic
+// created") and persists it.
+func (j *UpgradeJournal) RecordPhase(entry *JournalEntry, phase string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	entry.Phase = phase
+	return j.save(entry)
+}
+
+// RecordNodeStatus updates a single node's status within entry and
+// persists it. Safe to call from multiple worker-node goroutines
+// concurrently.
+func (j *UpgradeJournal) RecordNodeStatus(entry *JournalEntry, node string, status NodeUpgradeStatus, nodeErr error) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for i := range entry.Nodes {
+		if entry.Nodes[i].Node != node {
+			continue
+		}
+		entry.Nodes[i].Status = status
+		if nodeErr != nil {
+			entry.Nodes[i].LastError = nodeErr.Error()
+			entry.LastError = fmt.Sprintf("node %s: %v", node, nodeErr)
+		}
+		return j.save(entry)
+	}
+	return fmt.Errorf("node %s is not tracked in journal entry %s", node, entry.AttemptID)
+}
+
+// Complete marks entry as finished successfully.
+func (j *UpgradeJournal) Complete(entry *JournalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	entry.Completed = true
+	entry.Phase = "completed"
+	return j.save(entry)
+}
+
+// NodeStatus returns the tracked status for node, or NodePending if the
+// node isn't present (shouldn't happen in practice).
+func (e *JournalEntry) NodeStatus(node string) NodeUpgradeStatus {
+	for _, n := range e.Nodes {
+		if n.Node == node {
+			return n.Status
+		}
+	}
+	return NodePending
+}
+
+func sameNodeSet(tracked []NodeState, nodes []string) bool {
+	if len(tracked) != len(nodes) {
+		return false
+	}
+	trackedSet := make(map[string]bool, len(tracked))
+	for _, n := range tracked {
+		trackedSet[n.Node] = true
+	}
+	for _, n := range nodes {
+		if !trackedSet[n] {
+			return false
+		}
+	}
+	return true
+}
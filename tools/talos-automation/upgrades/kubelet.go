@@ -0,0 +1,121 @@
+package upgrades
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const kubeletConvergePollInterval = 5 * time.Second
+const defaultKubeletConvergeTimeout = 5 * time.Minute
+
+// UpgradeKubeletOnly patches each node's machine config
+// (.machine.kubelet.image) to version and waits for the corresponding
+// Node's status.nodeInfo.kubeletVersion to converge, without touching
+// the control plane and without rebooting the node.
+func (k *KubernetesUpgrader) UpgradeKubeletOnly(version string, nodes []string, executeCommands bool) error {
+	log.Printf("=== Kubelet-Only Upgrade Started ===")
+	log.Printf("Target kubelet version: %s, nodes: %v, execute: %t", version, nodes, executeCommands)
+
+	if !k.isValidVersion(version) {
+		return fmt.Errorf("invalid kubelet version format: %s", version)
+	}
+	cleanVersion := strings.TrimPrefix(version, "v")
+
+	if !executeCommands {
+		log.Printf("*** DRY RUN MODE: Would patch kubelet to %s on %d nodes ***", cleanVersion, len(nodes))
+		return nil
+	}
+
+	for i, node := range nodes {
+		log.Printf("Patching kubelet image on node %d/%d: %s", i+1, len(nodes), node)
+		if err := k.patchKubeletImage(node, cleanVersion); err != nil {
+			return fmt.Errorf("failed to patch kubelet image on node %s: %w", node, err)
+		}
+
+		if err := k.waitForKubeletVersion(node, cleanVersion, defaultKubeletConvergeTimeout); err != nil {
+			return fmt.Errorf("kubelet on node %s did not converge to %s: %w", node, cleanVersion, err)
+		}
+
+		log.Printf("Node %s kubelet converged to %s", node, cleanVersion)
+	}
+
+	log.Printf("Kubelet-only upgrade to %s completed successfully for all nodes", cleanVersion)
+	return nil
+}
+
+// patchKubeletImage applies a JSON patch to the node's machine config
+// setting the kubelet image to the target version, without requiring a
+// full talosctl upgrade/reboot.
+func (k *KubernetesUpgrader) patchKubeletImage(node, version string) error {
+	image := fmt.Sprintf("ghcr.io/siderolabs/kubelet:v%s", version)
+	patch := fmt.Sprintf(`[{"op": "replace", "path": "/machine/kubelet/image", "value": %q}]`, image)
+
+	args := []string{
+		"--talosconfig", k.TalosConfigPath,
+		"patch", "machineconfig",
+		"-n", node,
+		"-p", patch,
+	}
+
+	cmd := exec.Command("talosctl", args...)
+
+	timestamp := time.Now().Format("20060102-150405")
+	logFileName := fmt.Sprintf("kubelet-patch-%s-%s-%s.log", strings.ReplaceAll(node, ".", "-"), version, timestamp)
+	logFilePath := fmt.Sprintf("%s/%s", k.LogPath, logFileName)
+
+	logFile, err := os.Create(logFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to create log file: %w", err)
+	}
+	defer logFile.Close()
+
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+
+	log.Printf("Running: talosctl %s", strings.Join(args, " "))
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("talosctl patch machineconfig failed for node %s: %w", node, err)
+	}
+
+	return nil
+}
+
+// waitForKubeletVersion polls the Node object's
+// status.nodeInfo.kubeletVersion until it matches targetVersion or
+// timeout elapses.
+func (k *KubernetesUpgrader) waitForKubeletVersion(node, targetVersion string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	var lastErr error
+
+	for time.Now().Before(deadline) {
+		status, err := fetchNodeStatus(k.TalosConfigPath, node)
+		if err != nil {
+			lastErr = err
+			time.Sleep(kubeletConvergePollInterval)
+			continue
+		}
+
+		if strings.TrimPrefix(status.Status.NodeInfo.KubeletVersion, "v") == targetVersion {
+			return nil
+		}
+
+		lastErr = fmt.Errorf("node %s reports kubelet %s, want %s", node, status.Status.NodeInfo.KubeletVersion, targetVersion)
+		time.Sleep(kubeletConvergePollInterval)
+	}
+
+	return lastErr
+}
+
+// GetNodeKubeletVersion returns the real kubelet version the named node
+// currently reports, for diagnostics.
+func (k *KubernetesUpgrader) GetNodeKubeletVersion(node string) (string, error) {
+	status, err := fetchNodeStatus(k.TalosConfigPath, node)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(status.Status.NodeInfo.KubeletVersion, "v"), nil
+}
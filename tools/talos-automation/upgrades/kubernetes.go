@@ -21,6 +21,7 @@ import (
 type KubernetesUpgrader struct {
 	TalosConfigPath    string
 	LogPath            string
+	ForceSkipSkew      bool
 	mockCurrentVersion string
 }
 
@@ -70,12 +71,12 @@ func (k *KubernetesUpgrader) UpgradeToVersion(version, controlPlaneNode string,
 			return nil
 		}
 		
-		// Check for downgrades
-		if k.isDowngrade(currentVersion, cleanVersion) {
-			log.Printf("ERROR: Downgrade detected - refusing to downgrade from %s to %s", currentVersion, cleanVersion)
-			return fmt.Errorf("refusing to downgrade Kubernetes from %s to %s", currentVersion, cleanVersion)
+		// Enforce the shared downgrade + minor-skew policy
+		if err := validateUpgradePath("Kubernetes", currentVersion, cleanVersion, k.ForceSkipSkew); err != nil {
+			log.Printf("ERROR: %v", err)
+			return err
 		}
-		
+
 		log.Printf("DECISION: Upgrade needed from %s to %s", currentVersion, cleanVersion)
 	}
 
@@ -87,30 +88,56 @@ func (k *KubernetesUpgrader) UpgradeToVersion(version, controlPlaneNode string,
 	
 	log.Printf("*** PRODUCTION MODE: Executing actual upgrade commands ***")
 
+	journal := NewUpgradeJournal(k.LogPath, "kubernetes")
+	entry, err := journal.StartOrResume("", cleanVersion, []string{controlPlaneNode})
+	if err != nil {
+		return fmt.Errorf("failed to start/resume upgrade journal: %w", err)
+	}
+
 	// Run dry-run first
 	log.Printf("Running dry-run for Kubernetes upgrade...")
-	if err := k.runUpgradeCommand(cleanVersion, controlPlaneNode, true, executeCommands); err != nil {
+	if err := journal.RecordPhase(entry, "dry-run"); err != nil {
+		return fmt.Errorf("failed to update upgrade journal: %w", err)
+	}
+	if err := k.runUpgradeCommand(cleanVersion, currentVersion, controlPlaneNode, AllComponents, true, executeCommands); err != nil {
 		return fmt.Errorf("dry-run failed: %w", err)
 	}
 
 	log.Printf("Dry-run successful, proceeding with actual upgrade...")
-	
+
 	// Run actual upgrade
-	if err := k.runUpgradeCommand(cleanVersion, controlPlaneNode, false, executeCommands); err != nil {
+	if err := journal.RecordNodeStatus(entry, controlPlaneNode, NodeInProgress, nil); err != nil {
+		return fmt.Errorf("failed to update upgrade journal: %w", err)
+	}
+	if err := k.runUpgradeCommand(cleanVersion, currentVersion, controlPlaneNode, AllComponents, false, executeCommands); err != nil {
+		journal.RecordNodeStatus(entry, controlPlaneNode, NodeFailed, err)
 		return fmt.Errorf("upgrade failed: %w", err)
 	}
+	if err := journal.RecordNodeStatus(entry, controlPlaneNode, NodeSucceeded, nil); err != nil {
+		return fmt.Errorf("failed to update upgrade journal: %w", err)
+	}
+	if err := journal.Complete(entry); err != nil {
+		return fmt.Errorf("failed to complete upgrade journal: %w", err)
+	}
 
 	log.Printf("Kubernetes upgrade to version %s completed successfully", cleanVersion)
 	return nil
 }
 
-func (k *KubernetesUpgrader) runUpgradeCommand(version, node string, dryRun, executeCommands bool) error {
+// JournalStatus returns the last recorded Kubernetes upgrade attempt, or
+// nil if none has ever run.
+func (k *KubernetesUpgrader) JournalStatus() (*JournalEntry, error) {
+	return NewUpgradeJournal(k.LogPath, "kubernetes").Status()
+}
+
+func (k *KubernetesUpgrader) runUpgradeCommand(version, currentVersion, node string, components Components, dryRun, executeCommands bool) error {
 	args := []string{
 		"--talosconfig", k.TalosConfigPath,
 		"upgrade-k8s",
 		"--to", version,
 		"-n", node,
 	}
+	args = append(args, components.talosctlArgs(currentVersion)...)
 
 	if dryRun {
 		args = append(args, "--dry-run")
@@ -192,11 +219,6 @@ func (k *KubernetesUpgrader) isValidVersion(version string) bool {
 	return versionRegex.MatchString(version)
 }
 
-func (k *KubernetesUpgrader) isDowngrade(current, target string) bool {
-	// Simple string comparison for now - could be enhanced with proper semver parsing
-	return strings.Compare(current, target) > 0
-}
-
 func (k *KubernetesUpgrader) SetMockCurrentVersion(version string) {
     log.Printf("SetMockCurrentVersion called: setting mock version to %s", version)
     k.mockCurrentVersion = version
@@ -333,23 +355,32 @@ func parseKubeconfigForCreds(data []byte) (server string, ca, cert, key []byte,
     return
 }
 
-// getAPIServerVersion hits the /version endpoint and returns gitVersion
-func getAPIServerVersion(server string, ca, cert, key []byte, token string) (string, error) {
-    // Root CA pool
+// newTLSClient builds an http.Client trusting ca, and presenting cert/key
+// as a client certificate when both are provided. Shared by anything that
+// needs to talk to the Kubernetes API using kubeconfig-derived creds.
+func newTLSClient(ca, cert, key []byte) (*http.Client, error) {
     roots := x509.NewCertPool()
     if ok := roots.AppendCertsFromPEM(ca); !ok {
-        return "", fmt.Errorf("failed to parse CA cert")
+        return nil, fmt.Errorf("failed to parse CA cert")
     }
     tlsCfg := &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
     if len(cert) > 0 && len(key) > 0 {
         pair, err := tls.X509KeyPair(cert, key)
         if err != nil {
-            return "", fmt.Errorf("invalid client cert/key: %w", err)
+            return nil, fmt.Errorf("invalid client cert/key: %w", err)
         }
         tlsCfg.Certificates = []tls.Certificate{pair}
     }
     tr := &http.Transport{TLSClientConfig: tlsCfg}
-    client := &http.Client{Transport: tr, Timeout: 10 * time.Second}
+    return &http.Client{Transport: tr, Timeout: 10 * time.Second}, nil
+}
+
+// getAPIServerVersion hits the /version endpoint and returns gitVersion
+func getAPIServerVersion(server string, ca, cert, key []byte, token string) (string, error) {
+    client, err := newTLSClient(ca, cert, key)
+    if err != nil {
+        return "", err
+    }
 
     const maxRetries = 3
     const retryDelay = 5 * time.Second
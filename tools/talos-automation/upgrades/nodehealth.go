@@ -0,0 +1,156 @@
+package upgrades
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultNodeReadyTimeout bounds how long waitForNodeReady will poll a
+// single node before giving up and halting the rollout.
+const defaultNodeReadyTimeout = 10 * time.Minute
+
+const nodeReadyPollInterval = 10 * time.Second
+
+// nodeStatus is the subset of a Kubernetes Node object's status that
+// waitForNodeReady cares about.
+type nodeStatus struct {
+	Status struct {
+		NodeInfo struct {
+			KubeletVersion string `json:"kubeletVersion"`
+			OSImage        string `json:"osImage"`
+			KernelVersion  string `json:"kernelVersion"`
+		} `json:"nodeInfo"`
+		Conditions []struct {
+			Type   string `json:"type"`
+			Status string `json:"status"`
+		} `json:"conditions"`
+	} `json:"status"`
+}
+
+func (n *nodeStatus) ready() bool {
+	healthy := map[string]string{
+		"Ready":          "True",
+		"MemoryPressure": "False",
+		"DiskPressure":   "False",
+	}
+	seen := map[string]bool{}
+	for _, c := range n.Status.Conditions {
+		want, ok := healthy[c.Type]
+		if !ok {
+			continue
+		}
+		seen[c.Type] = true
+		if c.Status != want {
+			return false
+		}
+	}
+	// Every condition we care about must have actually been reported.
+	for cond := range healthy {
+		if !seen[cond] {
+			return false
+		}
+	}
+	return true
+}
+
+// fetchNodeStatus fetches the live Node object via the Kubernetes API,
+// reusing the kubeconfig plumbing KubernetesUpgrader already uses to
+// query the apiserver version.
+func fetchNodeStatus(talosConfigPath, node string) (*nodeStatus, error) {
+	k := &KubernetesUpgrader{TalosConfigPath: talosConfigPath}
+	kubeconfig, err := k.fetchKubeconfig(node)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch kubeconfig: %w", err)
+	}
+
+	server, ca, cert, key, token, err := parseKubeconfigForCreds(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse kubeconfig: %w", err)
+	}
+
+	client, err := newTLSClient(ca, cert, key)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, fmt.Sprintf("%s/api/v1/nodes/%s", server, node), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if token != "" && len(cert) == 0 {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query node %s: %w", node, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("node %s API query returned %d", node, resp.StatusCode)
+	}
+
+	var status nodeStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, fmt.Errorf("failed to decode node status: %w", err)
+	}
+
+	return &status, nil
+}
+
+// waitForNodeReady polls the Kubernetes API for node's Node object until
+// its osImage or kernelVersion contains targetVersion and it reports all
+// of Ready/MemoryPressure/DiskPressure healthy, or until timeout elapses.
+// kubeletVersion is not compared. A timeout of zero uses
+// defaultNodeReadyTimeout.
+func (t *TalosUpgrader) waitForNodeReady(node, targetVersion string, timeout time.Duration) error {
+	if timeout == 0 {
+		timeout = defaultNodeReadyTimeout
+	}
+
+	log.Printf("Waiting for node %s to report healthy after upgrade to %s (timeout %s)", node, targetVersion, timeout)
+
+	deadline := time.Now().Add(timeout)
+	var lastErr error
+
+	for time.Now().Before(deadline) {
+		status, err := fetchNodeStatus(t.TalosConfigPath, node)
+		if err != nil {
+			lastErr = err
+			log.Printf("Node %s not yet queryable: %v", node, err)
+			time.Sleep(nodeReadyPollInterval)
+			continue
+		}
+
+		osImageReflectsTarget := strings.Contains(status.Status.NodeInfo.OSImage, targetVersion) ||
+			strings.Contains(status.Status.NodeInfo.KernelVersion, targetVersion)
+
+		if !status.ready() {
+			log.Printf("Node %s conditions not yet healthy", node)
+			time.Sleep(nodeReadyPollInterval)
+			continue
+		}
+
+		if !osImageReflectsTarget {
+			log.Printf("Node %s is healthy but osImage/kernelVersion doesn't reflect %s yet (osImage=%s, kernelVersion=%s)",
+				node, targetVersion, status.Status.NodeInfo.OSImage, status.Status.NodeInfo.KernelVersion)
+			time.Sleep(nodeReadyPollInterval)
+			continue
+		}
+
+		log.Printf("Node %s is healthy and running the expected build", node)
+		return nil
+	}
+
+	if lastErr != nil {
+		return fmt.Errorf("node %s did not become healthy within %s: %w", node, timeout, lastErr)
+	}
+	return fmt.Errorf("node %s did not become healthy within %s", node, timeout)
+}
@@ -0,0 +1,396 @@
+package upgrades
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"talos-automation/internal/compatibility"
+	"talos-automation/internal/repo"
+	"talos-automation/internal/semver"
+	"talos-automation/internal/talos"
+)
+
+// ReleaseFetcher resolves the set of available Talos and Kubernetes releases.
+// It is mockable so the planner can be tested without hitting GitHub or
+// dl.k8s.io.
+type ReleaseFetcher interface {
+	FetchTalosReleases() ([]string, error)
+	FetchKubernetesStable(minor string) (string, error)
+}
+
+// GitHubReleaseFetcher is the default ReleaseFetcher, backed by the public
+// GitHub releases API for Talos and the dl.k8s.io stable-version endpoint
+// for Kubernetes. Results are cached for releaseCacheTTL, shared across
+// every GitHubReleaseFetcher instance, so a dashboard polling the plan
+// endpoint every few seconds doesn't hammer GitHub's and dl.k8s.io's
+// rate limits.
+type GitHubReleaseFetcher struct {
+	HTTPClient *http.Client
+}
+
+func NewGitHubReleaseFetcher() *GitHubReleaseFetcher {
+	return &GitHubReleaseFetcher{HTTPClient: http.DefaultClient}
+}
+
+// releaseCacheTTL is how long fetched release data is considered fresh.
+const releaseCacheTTL = 5 * time.Minute
+
+// releaseCache is the process-wide cache backing GitHubReleaseFetcher.
+// It's package-level rather than a field on GitHubReleaseFetcher because
+// planEndpoint constructs a fresh UpgradePlanner (and thus a fresh
+// fetcher) on every request.
+var releaseCache = struct {
+	mu             sync.Mutex
+	talosReleases  []string
+	talosFetchedAt time.Time
+	k8sStable      map[string]string
+	k8sFetchedAt   map[string]time.Time
+}{
+	k8sStable:    make(map[string]string),
+	k8sFetchedAt: make(map[string]time.Time),
+}
+
+type githubRelease struct {
+	TagName string `json:"tag_name"`
+	Draft   bool   `json:"draft"`
+}
+
+// FetchTalosReleases returns the non-draft release tags of siderolabs/talos,
+// newest first, as reported by the GitHub releases API.
+func (f *GitHubReleaseFetcher) FetchTalosReleases() ([]string, error) {
+	releaseCache.mu.Lock()
+	if releaseCache.talosReleases != nil && time.Since(releaseCache.talosFetchedAt) < releaseCacheTTL {
+		cached := releaseCache.talosReleases
+		releaseCache.mu.Unlock()
+		return cached, nil
+	}
+	releaseCache.mu.Unlock()
+
+	req, err := http.NewRequest("GET", "https://api.github.com/repos/siderolabs/talos/releases", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+
+	resp, err := f.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch Talos releases: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("GitHub releases API error %d: %s", resp.StatusCode, string(body))
+	}
+
+	var releases []githubRelease
+	if err := json.NewDecoder(resp.Body).Decode(&releases); err != nil {
+		return nil, fmt.Errorf("failed to decode releases response: %w", err)
+	}
+
+	versions := make([]string, 0, len(releases))
+	for _, r := range releases {
+		if r.Draft {
+			continue
+		}
+		versions = append(versions, strings.TrimPrefix(r.TagName, "v"))
+	}
+
+	releaseCache.mu.Lock()
+	releaseCache.talosReleases = versions
+	releaseCache.talosFetchedAt = time.Now()
+	releaseCache.mu.Unlock()
+
+	return versions, nil
+}
+
+// FetchKubernetesStable returns the latest stable patch version for the
+// given minor (e.g. "1.33") via dl.k8s.io/release/stable-1.33.txt. Pass an
+// empty minor to get the overall latest stable release.
+func (f *GitHubReleaseFetcher) FetchKubernetesStable(minor string) (string, error) {
+	releaseCache.mu.Lock()
+	if fetchedAt, ok := releaseCache.k8sFetchedAt[minor]; ok && time.Since(fetchedAt) < releaseCacheTTL {
+		cached := releaseCache.k8sStable[minor]
+		releaseCache.mu.Unlock()
+		return cached, nil
+	}
+	releaseCache.mu.Unlock()
+
+	url := "https://dl.k8s.io/release/stable.txt"
+	if minor != "" {
+		url = fmt.Sprintf("https://dl.k8s.io/release/stable-%s.txt", minor)
+	}
+
+	resp, err := f.HTTPClient.Get(url)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch stable version: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("dl.k8s.io error %d: %s", resp.StatusCode, string(body))
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read stable version response: %w", err)
+	}
+
+	stable := strings.TrimPrefix(strings.TrimSpace(string(body)), "v")
+
+	releaseCache.mu.Lock()
+	releaseCache.k8sStable[minor] = stable
+	releaseCache.k8sFetchedAt[minor] = time.Now()
+	releaseCache.mu.Unlock()
+
+	return stable, nil
+}
+
+// UpgradePlan is the structured result of a Plan() call, suitable for
+// printing as text or encoding as JSON.
+type UpgradePlan struct {
+	CurrentTalos          string   `json:"currentTalos"`
+	CurrentKubernetes     string   `json:"currentKubernetes"`
+	LatestTalosPatch      string   `json:"latestTalosPatch"`
+	NextTalosMinor        string   `json:"nextTalosMinor,omitempty"`
+	LatestKubernetesPatch string   `json:"latestKubernetesPatch"`
+	NextKubernetesMinor   string   `json:"nextKubernetesMinor,omitempty"`
+	TargetTalos           string   `json:"targetTalos,omitempty"`
+	TargetKubernetes      string   `json:"targetKubernetes,omitempty"`
+	Compatible            bool     `json:"compatible"`
+	Warnings              []string `json:"warnings,omitempty"`
+}
+
+// UpgradePlanner inspects the running cluster and the upstream release
+// feeds to produce a dry-run summary before UpgradeToVersion is invoked,
+// similar in spirit to `kubeadm upgrade plan`.
+type UpgradePlanner struct {
+	TalosConfigPath string
+	LogPath         string
+	Source          repo.Source
+	Releases        ReleaseFetcher
+}
+
+func NewUpgradePlanner(talosConfigPath, logPath string, source repo.Source) *UpgradePlanner {
+	return &UpgradePlanner{
+		TalosConfigPath: talosConfigPath,
+		LogPath:         logPath,
+		Source:          source,
+		Releases:        NewGitHubReleaseFetcher(),
+	}
+}
+
+// Plan fetches current and available versions and returns a structured
+// summary. toTalos/toKubernetes may be empty, in which case no target
+// validation is performed.
+func (p *UpgradePlanner) Plan(toTalos, toKubernetes string) (*UpgradePlan, error) {
+	log.Printf("=== Upgrade Plan Requested ===")
+
+	talosUpgrader := NewTalosUpgrader(p.TalosConfigPath, p.LogPath, p.Source)
+	currentTalos, err := talosUpgrader.GetCurrentVersion(true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine current Talos version: %w", err)
+	}
+
+	k8sUpgrader := NewKubernetesUpgrader(p.TalosConfigPath, p.LogPath)
+	currentKubernetes, err := p.currentKubernetesVersion(k8sUpgrader)
+	if err != nil {
+		log.Printf("WARNING: failed to determine current Kubernetes version: %v", err)
+	}
+
+	plan := &UpgradePlan{
+		CurrentTalos:      currentTalos,
+		CurrentKubernetes: currentKubernetes,
+		TargetTalos:       toTalos,
+		TargetKubernetes:  toKubernetes,
+		Compatible:        true,
+	}
+	if currentKubernetes == "" {
+		plan.Warnings = append(plan.Warnings, "could not determine current Kubernetes version")
+	}
+
+	talosReleases, err := p.Releases.FetchTalosReleases()
+	if err != nil {
+		log.Printf("WARNING: failed to fetch Talos releases: %v", err)
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("could not fetch Talos releases: %v", err))
+	} else {
+		currentMinor := minorOf(currentTalos)
+		plan.LatestTalosPatch = latestPatchForMinor(talosReleases, currentMinor)
+		plan.NextTalosMinor = nextMinor(talosReleases, currentMinor)
+	}
+
+	if toKubernetes != "" {
+		latestPatch, err := p.Releases.FetchKubernetesStable(minorOf(toKubernetes))
+		if err != nil {
+			log.Printf("WARNING: failed to fetch Kubernetes stable version: %v", err)
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("could not fetch Kubernetes stable version: %v", err))
+		} else {
+			plan.LatestKubernetesPatch = latestPatch
+			if latestPatch != toKubernetes {
+				plan.Warnings = append(plan.Warnings, fmt.Sprintf("target Kubernetes %s is not the latest patch on its minor (%s)", toKubernetes, latestPatch))
+			}
+		}
+	}
+
+	if toTalos != "" {
+		if err := p.validateTalosHop(currentTalos, toTalos); err != nil {
+			plan.Compatible = false
+			plan.Warnings = append(plan.Warnings, err.Error())
+		}
+	}
+
+	if toTalos != "" && toKubernetes != "" {
+		if err := p.validateSupportMatrix(toTalos, toKubernetes); err != nil {
+			plan.Compatible = false
+			plan.Warnings = append(plan.Warnings, err.Error())
+		}
+	}
+
+	return plan, nil
+}
+
+// currentKubernetesVersion resolves a control-plane node from the talos
+// config and asks it for the running Kubernetes version. Returns an
+// empty string (with an error an operator can read in the logs) if
+// either step fails, since a plan should still render with a blank
+// "current Kubernetes" field rather than abort entirely.
+func (p *UpgradePlanner) currentKubernetesVersion(k8sUpgrader *KubernetesUpgrader) (string, error) {
+	talosConfig, err := talos.ParseConfig(p.TalosConfigPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse talos config: %w", err)
+	}
+
+	controlPlaneNode, err := talosConfig.GetFirstControlPlaneNode()
+	if err != nil {
+		return "", fmt.Errorf("failed to get control plane node: %w", err)
+	}
+
+	version, err := k8sUpgrader.GetCurrentVersion(controlPlaneNode, true)
+	if err != nil {
+		return "", fmt.Errorf("failed to get current Kubernetes version from %s: %w", controlPlaneNode, err)
+	}
+	return version, nil
+}
+
+// validateTalosHop rejects Talos targets that skip more than one minor.
+func (p *UpgradePlanner) validateTalosHop(current, target string) error {
+	currentMinor, err := strconv.Atoi(strings.Split(minorOf(current), ".")[1])
+	if err != nil {
+		return nil
+	}
+	targetMinor, err := strconv.Atoi(strings.Split(minorOf(target), ".")[1])
+	if err != nil {
+		return nil
+	}
+	if targetMinor-currentMinor > 1 {
+		return fmt.Errorf("Talos upgrade from %s to %s skips more than one minor version", current, target)
+	}
+	return nil
+}
+
+// validateSupportMatrix checks the target Kubernetes version against the
+// tested range published for the target Talos minor, using the same
+// compatibility.SupportedKubernetesRange table the compatibility package
+// enforces elsewhere.
+func (p *UpgradePlanner) validateSupportMatrix(talosTarget, k8sTarget string) error {
+	talosMinor := minorOf(talosTarget)
+	min, max, ok := compatibility.SupportedKubernetesRange(talosMinor)
+	if !ok {
+		return fmt.Errorf("no known Kubernetes support range for Talos %s; verify manually", talosMinor)
+	}
+	k8sMinor := minorOf(k8sTarget)
+	if compareMinor(k8sMinor, min) < 0 || compareMinor(k8sMinor, max) > 0 {
+		return fmt.Errorf("Kubernetes %s is outside the tested range [%s, %s] for Talos %s", k8sTarget, min, max, talosMinor)
+	}
+	return nil
+}
+
+// String renders the plan the way an operator reading a terminal would
+// expect: current state, what's available, and whether the requested
+// target combination is safe.
+func (p *UpgradePlan) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Current Talos:       %s\n", p.CurrentTalos)
+	if p.LatestTalosPatch != "" {
+		fmt.Fprintf(&b, "Latest Talos patch:  %s\n", p.LatestTalosPatch)
+	}
+	if p.NextTalosMinor != "" {
+		fmt.Fprintf(&b, "Next Talos minor:    %s\n", p.NextTalosMinor)
+	}
+	if p.CurrentKubernetes != "" {
+		fmt.Fprintf(&b, "Current Kubernetes:  %s\n", p.CurrentKubernetes)
+	}
+	if p.LatestKubernetesPatch != "" {
+		fmt.Fprintf(&b, "Latest K8s patch:    %s\n", p.LatestKubernetesPatch)
+	}
+	if p.TargetTalos != "" || p.TargetKubernetes != "" {
+		fmt.Fprintf(&b, "Target:              Talos %s / Kubernetes %s\n", p.TargetTalos, p.TargetKubernetes)
+		fmt.Fprintf(&b, "Compatible:          %t\n", p.Compatible)
+	}
+	for _, w := range p.Warnings {
+		fmt.Fprintf(&b, "WARNING: %s\n", w)
+	}
+	return b.String()
+}
+
+var minorRegexp = regexp.MustCompile(`^v?(\d+\.\d+)`)
+
+func minorOf(version string) string {
+	matches := minorRegexp.FindStringSubmatch(version)
+	if len(matches) < 2 {
+		return version
+	}
+	return matches[1]
+}
+
+func compareMinor(a, b string) int {
+	aParts := strings.SplitN(a, ".", 2)
+	bParts := strings.SplitN(b, ".", 2)
+	if len(aParts) < 2 || len(bParts) < 2 {
+		return strings.Compare(a, b)
+	}
+	aMajor, _ := strconv.Atoi(aParts[0])
+	bMajor, _ := strconv.Atoi(bParts[0])
+	if aMajor != bMajor {
+		return aMajor - bMajor
+	}
+	aMinor, _ := strconv.Atoi(aParts[1])
+	bMinor, _ := strconv.Atoi(bParts[1])
+	return aMinor - bMinor
+}
+
+func latestPatchForMinor(versions []string, minor string) string {
+	var latest string
+	for _, v := range versions {
+		if minorOf(v) != minor {
+			continue
+		}
+		if latest == "" || semver.Compare(v, latest) > 0 {
+			latest = v
+		}
+	}
+	return latest
+}
+
+func nextMinor(versions []string, currentMinor string) string {
+	var best string
+	for _, v := range versions {
+		vMinor := minorOf(v)
+		if compareMinor(vMinor, currentMinor) <= 0 {
+			continue
+		}
+		if best == "" || compareMinor(vMinor, minorOf(best)) < 0 {
+			best = v
+		}
+	}
+	return best
+}
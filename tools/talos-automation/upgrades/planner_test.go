@@ -0,0 +1,80 @@
+package upgrades
+
+import "testing"
+
+func TestMinorOf(t *testing.T) {
+	cases := map[string]string{
+		"1.33.4":  "1.33",
+		"v1.9.1":  "1.9",
+		"1.10":    "1.10",
+		"garbage": "garbage",
+	}
+	for input, want := range cases {
+		if got := minorOf(input); got != want {
+			t.Errorf("minorOf(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestCompareMinor(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"1.9", "1.10", -1},
+		{"1.10", "1.9", 1},
+		{"1.9", "1.9", 0},
+		{"1.10", "1.10", 0},
+	}
+	for _, c := range cases {
+		got := compareMinor(c.a, c.b)
+		if (got < 0) != (c.want < 0) || (got > 0) != (c.want > 0) || (got == 0) != (c.want == 0) {
+			t.Errorf("compareMinor(%q, %q) = %d, want sign %d", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestLatestPatchForMinor(t *testing.T) {
+	versions := []string{"1.9.0", "1.9.3", "1.9.1", "1.10.0"}
+	if got := latestPatchForMinor(versions, "1.9"); got != "1.9.3" {
+		t.Errorf("latestPatchForMinor() = %q, want 1.9.3", got)
+	}
+	if got := latestPatchForMinor(versions, "1.11"); got != "" {
+		t.Errorf("latestPatchForMinor() for absent minor = %q, want empty", got)
+	}
+}
+
+func TestNextMinor(t *testing.T) {
+	versions := []string{"1.9.0", "1.10.0", "1.10.1", "1.11.0"}
+	if got := nextMinor(versions, "1.9"); got != "1.10.0" && got != "1.10.1" {
+		t.Errorf("nextMinor() = %q, want a 1.10.x release", got)
+	}
+	if got := nextMinor(versions, "1.11"); got != "" {
+		t.Errorf("nextMinor() past the newest minor = %q, want empty", got)
+	}
+}
+
+func TestValidateTalosHop(t *testing.T) {
+	p := &UpgradePlanner{}
+
+	if err := p.validateTalosHop("1.8.0", "1.9.0"); err != nil {
+		t.Errorf("single minor hop rejected: %v", err)
+	}
+	if err := p.validateTalosHop("1.8.0", "1.10.0"); err == nil {
+		t.Error("expected error skipping more than one minor, got nil")
+	}
+}
+
+func TestValidateSupportMatrix(t *testing.T) {
+	p := &UpgradePlanner{}
+
+	if err := p.validateSupportMatrix("1.9", "1.30"); err != nil {
+		t.Errorf("in-range target rejected: %v", err)
+	}
+	if err := p.validateSupportMatrix("1.9", "1.33"); err == nil {
+		t.Error("expected error for Kubernetes version outside the support matrix, got nil")
+	}
+	if err := p.validateSupportMatrix("1.99", "1.30"); err == nil {
+		t.Error("expected error for unknown Talos minor, got nil")
+	}
+}
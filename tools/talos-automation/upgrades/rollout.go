@@ -0,0 +1,649 @@
+package upgrades
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// RolloutNodeState tracks a single node's progress through the
+// cordon/drain/upgrade/health-check/uncordon state machine.
+type RolloutNodeState string
+
+const (
+	RolloutPending   RolloutNodeState = "pending"
+	RolloutCordoned  RolloutNodeState = "cordoned"
+	RolloutDrained   RolloutNodeState = "drained"
+	RolloutUpgraded  RolloutNodeState = "upgraded"
+	RolloutHealthy   RolloutNodeState = "healthy"
+	RolloutSucceeded RolloutNodeState = "succeeded"
+	RolloutFailed    RolloutNodeState = "failed"
+)
+
+// RolloutNode is one node's entry in a RolloutState.
+type RolloutNode struct {
+	Node      string           `json:"node"`
+	State     RolloutNodeState `json:"state"`
+	LastError string           `json:"lastError,omitempty"`
+}
+
+// RolloutState is the persisted state of a progressive node rollout,
+// distinct from UpgradeJournal: it tracks the cordon/drain/health-gate
+// machinery per node rather than just upgrade-command success, and
+// records the last version every node was known-healthy on so a retry
+// via the webhook can resume instead of restarting the whole rollout.
+type RolloutState struct {
+	TargetVersion   string        `json:"targetVersion"`
+	LastGoodVersion string        `json:"lastGoodVersion,omitempty"`
+	Nodes           []RolloutNode `json:"nodes"`
+	UpdatedAt       string        `json:"updatedAt"`
+}
+
+// NodeState returns the tracked state for node, or RolloutPending if the
+// node isn't present yet.
+func (s *RolloutState) NodeState(node string) RolloutNodeState {
+	for _, n := range s.Nodes {
+		if n.Node == node {
+			return n.State
+		}
+	}
+	return RolloutPending
+}
+
+const rolloutStateFile = "rollout-state.json"
+
+// RolloutJournal persists a RolloutState to LOG_PATH/rollout-state.json,
+// the same pattern UpgradeJournal uses for upgrade attempts. It also
+// serializes concurrent node updates, since worker nodes can drain in
+// parallel under MaxUnavailable.
+type RolloutJournal struct {
+	LogPath string
+	mu      sync.Mutex
+}
+
+func NewRolloutJournal(logPath string) *RolloutJournal {
+	return &RolloutJournal{LogPath: logPath}
+}
+
+func (j *RolloutJournal) path() string {
+	return filepath.Join(j.LogPath, rolloutStateFile)
+}
+
+// Status returns the most recently recorded rollout state, or nil if no
+// rollout has ever run. This backs the /rollout/status endpoint.
+func (j *RolloutJournal) Status() (*RolloutState, error) {
+	return j.load()
+}
+
+func (j *RolloutJournal) load() (*RolloutState, error) {
+	data, err := os.ReadFile(j.path())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rollout state: %w", err)
+	}
+	var state RolloutState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse rollout state: %w", err)
+	}
+	return &state, nil
+}
+
+func (j *RolloutJournal) save(state *RolloutState) error {
+	state.UpdatedAt = time.Now().Format(time.RFC3339)
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal rollout state: %w", err)
+	}
+	if err := os.WriteFile(j.path(), data, 0o644); err != nil {
+		return fmt.Errorf("failed to write rollout state: %w", err)
+	}
+	return nil
+}
+
+// StartOrResume returns the existing rollout state if one is already
+// tracking targetVersion, so nodes that already succeeded on a previous,
+// interrupted attempt are skipped; a node that stopped partway through is
+// taken through every step again, starting from cordon. Otherwise it
+// starts a fresh rollout, carrying over the last known-good version.
+func (j *RolloutJournal) StartOrResume(targetVersion string, nodes []string) (*RolloutState, error) {
+	existing, err := j.load()
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.TargetVersion == targetVersion {
+		log.Printf("Resuming node rollout to %s", targetVersion)
+		return existing, nil
+	}
+
+	state := &RolloutState{TargetVersion: targetVersion}
+	if existing != nil {
+		state.LastGoodVersion = existing.LastGoodVersion
+	}
+	for _, node := range nodes {
+		state.Nodes = append(state.Nodes, RolloutNode{Node: node, State: RolloutPending})
+	}
+
+	log.Printf("Starting new node rollout to %s", targetVersion)
+	if err := j.save(state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// RecordNodeState updates node's state within state and persists it.
+// Safe to call from multiple worker-node goroutines concurrently.
+func (j *RolloutJournal) RecordNodeState(state *RolloutState, node string, nodeState RolloutNodeState, nodeErr error) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	found := false
+	for i := range state.Nodes {
+		if state.Nodes[i].Node == node {
+			state.Nodes[i].State = nodeState
+			if nodeErr != nil {
+				state.Nodes[i].LastError = nodeErr.Error()
+			} else {
+				state.Nodes[i].LastError = ""
+			}
+			found = true
+			break
+		}
+	}
+	if !found {
+		rn := RolloutNode{Node: node, State: nodeState}
+		if nodeErr != nil {
+			rn.LastError = nodeErr.Error()
+		}
+		state.Nodes = append(state.Nodes, rn)
+	}
+	return j.save(state)
+}
+
+// Complete records targetVersion as the last known-good version for the
+// whole cluster.
+func (j *RolloutJournal) Complete(state *RolloutState, targetVersion string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	state.LastGoodVersion = targetVersion
+	return j.save(state)
+}
+
+// RolloutStatus returns the current node rollout state, or nil if no
+// rollout has ever run. This is what the /rollout/status endpoint reports.
+func RolloutStatus(logPath string) (*RolloutState, error) {
+	return NewRolloutJournal(logPath).Status()
+}
+
+// rolloutNodes drives controlPlaneNodes through the node state machine
+// strictly serially (a lost control-plane node is worse than a slow
+// rollout), then workerNodes with up to t.MaxUnavailable draining at
+// once. Any node failure halts the rollout, leaving that node cordoned.
+func (t *TalosUpgrader) rolloutNodes(journal *UpgradeJournal, entry *JournalEntry, controlPlaneNodes, workerNodes []string, targetVersion string, upgradeFn func(node string) error) error {
+	rollout := NewRolloutJournal(t.LogPath)
+	allNodes := append(append([]string{}, controlPlaneNodes...), workerNodes...)
+	state, err := rollout.StartOrResume(targetVersion, allNodes)
+	if err != nil {
+		return fmt.Errorf("failed to start/resume node rollout: %w", err)
+	}
+
+	log.Printf("Rolling out Talos %s to %d control-plane node(s) serially", targetVersion, len(controlPlaneNodes))
+	for _, node := range controlPlaneNodes {
+		if err := t.rolloutNode(journal, entry, rollout, state, node, targetVersion, true, upgradeFn); err != nil {
+			return err
+		}
+	}
+
+	maxUnavailable := t.MaxUnavailable
+	if maxUnavailable < 1 {
+		maxUnavailable = 1
+	}
+	log.Printf("Rolling out Talos %s to %d worker node(s), up to %d at a time", targetVersion, len(workerNodes), maxUnavailable)
+
+	sem := make(chan struct{}, maxUnavailable)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+
+	for _, node := range workerNodes {
+		sem <- struct{}{}
+		mu.Lock()
+		halted := firstErr != nil
+		mu.Unlock()
+		if halted {
+			<-sem
+			break
+		}
+
+		node := node
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := t.rolloutNode(journal, entry, rollout, state, node, targetVersion, false, upgradeFn); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+
+	return rollout.Complete(state, targetVersion)
+}
+
+// rolloutNode takes a single node through cordon, drain, upgrade, health
+// gate (Node.Ready, control-plane static pods, kube-system DaemonSet
+// rollout), and uncordon, recording its progress in both the rollout
+// state and the upgrade journal as it goes.
+func (t *TalosUpgrader) rolloutNode(journal *UpgradeJournal, entry *JournalEntry, rollout *RolloutJournal, state *RolloutState, node, targetVersion string, controlPlane bool, upgradeFn func(node string) error) error {
+	if state.NodeState(node) == RolloutSucceeded {
+		log.Printf("Node %s already succeeded in this rollout, skipping", node)
+		return nil
+	}
+
+	journal.RecordNodeStatus(entry, node, NodeInProgress, nil)
+
+	api, err := newK8sAPI(t.TalosConfigPath, node)
+	if err != nil {
+		return t.failRollout(journal, entry, rollout, state, node, fmt.Errorf("failed to build kubernetes API client via %s: %w", node, err))
+	}
+
+	log.Printf("Cordoning node %s", node)
+	if err := api.setNodeSchedulable(node, false); err != nil {
+		return t.failRollout(journal, entry, rollout, state, node, fmt.Errorf("failed to cordon node %s: %w", node, err))
+	}
+	rollout.RecordNodeState(state, node, RolloutCordoned, nil)
+
+	log.Printf("Draining node %s", node)
+	if err := api.drainNode(node); err != nil {
+		return t.failRollout(journal, entry, rollout, state, node, fmt.Errorf("failed to drain node %s: %w", node, err))
+	}
+	rollout.RecordNodeState(state, node, RolloutDrained, nil)
+
+	log.Printf("Upgrading node %s to %s", node, targetVersion)
+	if err := upgradeFn(node); err != nil {
+		return t.failRollout(journal, entry, rollout, state, node, fmt.Errorf("failed to upgrade node %s: %w", node, err))
+	}
+	rollout.RecordNodeState(state, node, RolloutUpgraded, nil)
+
+	log.Printf("Waiting for node %s to report healthy", node)
+	if err := t.waitForNodeReady(node, targetVersion, 0); err != nil {
+		return t.failRollout(journal, entry, rollout, state, node, fmt.Errorf("node %s did not become healthy after upgrade: %w", node, err))
+	}
+
+	if controlPlane {
+		log.Printf("Waiting for control-plane static pods on %s", node)
+		if err := api.waitForStaticPodsReady(node); err != nil {
+			return t.failRollout(journal, entry, rollout, state, node, err)
+		}
+	}
+
+	log.Printf("Waiting for kube-system DaemonSet rollout after upgrading %s", node)
+	if err := api.waitForDaemonSetRollout(); err != nil {
+		return t.failRollout(journal, entry, rollout, state, node, err)
+	}
+	rollout.RecordNodeState(state, node, RolloutHealthy, nil)
+
+	log.Printf("Uncordoning node %s", node)
+	if err := api.setNodeSchedulable(node, true); err != nil {
+		return t.failRollout(journal, entry, rollout, state, node, fmt.Errorf("failed to uncordon node %s: %w", node, err))
+	}
+
+	rollout.RecordNodeState(state, node, RolloutSucceeded, nil)
+	journal.RecordNodeStatus(entry, node, NodeSucceeded, nil)
+	log.Printf("Successfully rolled out %s to node %s", targetVersion, node)
+	return nil
+}
+
+// failRollout records node as failed in both the rollout state and the
+// upgrade journal, leaving it cordoned so an operator can investigate
+// before retrying, and returns the original error so the caller can
+// propagate it unchanged.
+func (t *TalosUpgrader) failRollout(journal *UpgradeJournal, entry *JournalEntry, rollout *RolloutJournal, state *RolloutState, node string, nodeErr error) error {
+	rollout.RecordNodeState(state, node, RolloutFailed, nodeErr)
+	journal.RecordNodeStatus(entry, node, NodeFailed, nodeErr)
+	return nodeErr
+}
+
+// k8sAPI is a thin Kubernetes REST client scoped to the credentials
+// fetched from a single node's kubeconfig, shared by the cordon/drain/
+// health-gate helpers below.
+type k8sAPI struct {
+	client *http.Client
+	server string
+	token  string
+	cert   []byte
+}
+
+// newK8sAPI fetches a kubeconfig via node and builds a k8sAPI from it,
+// reusing the same kubeconfig plumbing KubernetesUpgrader uses to query
+// the apiserver version.
+func newK8sAPI(talosConfigPath, node string) (*k8sAPI, error) {
+	k := &KubernetesUpgrader{TalosConfigPath: talosConfigPath}
+	kubeconfig, err := k.fetchKubeconfig(node)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch kubeconfig: %w", err)
+	}
+
+	server, ca, cert, key, token, err := parseKubeconfigForCreds(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse kubeconfig: %w", err)
+	}
+
+	client, err := newTLSClient(ca, cert, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &k8sAPI{client: client, server: server, token: token, cert: cert}, nil
+}
+
+func (a *k8sAPI) newRequest(method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(context.Background(), method, a.server+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if a.token != "" && len(a.cert) == 0 {
+		req.Header.Set("Authorization", "Bearer "+a.token)
+	}
+	return req, nil
+}
+
+// setNodeSchedulable cordons (schedulable=false) or uncordons
+// (schedulable=true) node via a merge patch to its Node spec.
+func (a *k8sAPI) setNodeSchedulable(node string, schedulable bool) error {
+	patch := []byte(fmt.Sprintf(`{"spec":{"unschedulable":%t}}`, !schedulable))
+	req, err := a.newRequest(http.MethodPatch, "/api/v1/nodes/"+node, bytes.NewReader(patch))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to patch node %s: %w", node, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("node %s schedulability patch returned %d: %s", node, resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+type podList struct {
+	Items []struct {
+		Metadata struct {
+			Name            string            `json:"name"`
+			Namespace       string            `json:"namespace"`
+			Annotations     map[string]string `json:"annotations"`
+			OwnerReferences []struct {
+				Kind string `json:"kind"`
+			} `json:"ownerReferences"`
+		} `json:"metadata"`
+	} `json:"items"`
+}
+
+func (p *podList) evictable() []struct {
+	Namespace string
+	Name      string
+} {
+	var out []struct {
+		Namespace string
+		Name      string
+	}
+	for _, pod := range p.Items {
+		if _, isStatic := pod.Metadata.Annotations["kubernetes.io/config.mirror"]; isStatic {
+			continue
+		}
+		isDaemonSetPod := false
+		for _, owner := range pod.Metadata.OwnerReferences {
+			if owner.Kind == "DaemonSet" {
+				isDaemonSetPod = true
+				break
+			}
+		}
+		if isDaemonSetPod {
+			continue
+		}
+		out = append(out, struct {
+			Namespace string
+			Name      string
+		}{Namespace: pod.Metadata.Namespace, Name: pod.Metadata.Name})
+	}
+	return out
+}
+
+const (
+	drainPollInterval = 5 * time.Second
+	drainTimeout      = 5 * time.Minute
+)
+
+// drainNode evicts every non-static, non-DaemonSet pod scheduled on node
+// and waits for them to actually terminate before returning.
+func (a *k8sAPI) drainNode(node string) error {
+	pods, err := a.listPods(node)
+	if err != nil {
+		return fmt.Errorf("failed to list pods on node %s: %w", node, err)
+	}
+
+	for _, pod := range pods.evictable() {
+		if err := a.evictPod(pod.Namespace, pod.Name); err != nil {
+			return fmt.Errorf("failed to evict pod %s/%s: %w", pod.Namespace, pod.Name, err)
+		}
+	}
+
+	deadline := time.Now().Add(drainTimeout)
+	for time.Now().Before(deadline) {
+		pods, err := a.listPods(node)
+		if err != nil {
+			return fmt.Errorf("failed to re-list pods on node %s: %w", node, err)
+		}
+		remaining := len(pods.evictable())
+		if remaining == 0 {
+			return nil
+		}
+		log.Printf("Node %s still has %d evictable pod(s), waiting for drain to finish", node, remaining)
+		time.Sleep(drainPollInterval)
+	}
+	return fmt.Errorf("node %s did not drain within %s", node, drainTimeout)
+}
+
+func (a *k8sAPI) listPods(node string) (*podList, error) {
+	req, err := a.newRequest(http.MethodGet, "/api/v1/pods?fieldSelector=spec.nodeName="+node, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("pod list returned %d: %s", resp.StatusCode, string(body))
+	}
+
+	var pods podList
+	if err := json.NewDecoder(resp.Body).Decode(&pods); err != nil {
+		return nil, fmt.Errorf("failed to decode pod list: %w", err)
+	}
+	return &pods, nil
+}
+
+func (a *k8sAPI) evictPod(namespace, name string) error {
+	eviction := map[string]interface{}{
+		"apiVersion": "policy/v1",
+		"kind":       "Eviction",
+		"metadata": map[string]string{
+			"name":      name,
+			"namespace": namespace,
+		},
+	}
+	body, err := json.Marshal(eviction)
+	if err != nil {
+		return fmt.Errorf("failed to marshal eviction: %w", err)
+	}
+
+	req, err := a.newRequest(http.MethodPost, fmt.Sprintf("/api/v1/namespaces/%s/pods/%s/eviction", namespace, name), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// A 404 means the pod is already gone, which is fine.
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("eviction returned %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+const (
+	staticPodPollInterval = 5 * time.Second
+	staticPodTimeout      = 5 * time.Minute
+)
+
+// controlPlaneStaticPods are the Talos-managed static pod name prefixes
+// every control-plane node runs; Talos names them "<component>-<node>".
+var controlPlaneStaticPods = []string{"kube-apiserver", "kube-controller-manager", "kube-scheduler"}
+
+// waitForStaticPodsReady polls until node's control-plane static pods all
+// report Ready, or staticPodTimeout elapses.
+func (a *k8sAPI) waitForStaticPodsReady(node string) error {
+	deadline := time.Now().Add(staticPodTimeout)
+	for time.Now().Before(deadline) {
+		allReady := true
+		for _, component := range controlPlaneStaticPods {
+			podName := fmt.Sprintf("%s-%s", component, node)
+			ready, err := a.podReady("kube-system", podName)
+			if err != nil || !ready {
+				allReady = false
+				break
+			}
+		}
+		if allReady {
+			return nil
+		}
+		time.Sleep(staticPodPollInterval)
+	}
+	return fmt.Errorf("control-plane static pods on %s did not become ready within %s", node, staticPodTimeout)
+}
+
+type podStatus struct {
+	Status struct {
+		Phase      string `json:"phase"`
+		Conditions []struct {
+			Type   string `json:"type"`
+			Status string `json:"status"`
+		} `json:"conditions"`
+	} `json:"status"`
+}
+
+func (a *k8sAPI) podReady(namespace, name string) (bool, error) {
+	req, err := a.newRequest(http.MethodGet, fmt.Sprintf("/api/v1/namespaces/%s/pods/%s", namespace, name), nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, nil
+	}
+
+	var pod podStatus
+	if err := json.NewDecoder(resp.Body).Decode(&pod); err != nil {
+		return false, err
+	}
+	if pod.Status.Phase != "Running" {
+		return false, nil
+	}
+	for _, c := range pod.Status.Conditions {
+		if c.Type == "Ready" {
+			return c.Status == "True", nil
+		}
+	}
+	return false, nil
+}
+
+const (
+	daemonSetPollInterval = 5 * time.Second
+	daemonSetTimeout      = 5 * time.Minute
+)
+
+type daemonSetList struct {
+	Items []struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+		Status struct {
+			DesiredNumberScheduled int `json:"desiredNumberScheduled"`
+			NumberReady            int `json:"numberReady"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// waitForDaemonSetRollout polls every kube-system DaemonSet until each
+// reports numberReady == desiredNumberScheduled, or daemonSetTimeout
+// elapses.
+func (a *k8sAPI) waitForDaemonSetRollout() error {
+	deadline := time.Now().Add(daemonSetTimeout)
+	for time.Now().Before(deadline) {
+		req, err := a.newRequest(http.MethodGet, "/apis/apps/v1/namespaces/kube-system/daemonsets", nil)
+		if err != nil {
+			return err
+		}
+		resp, err := a.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to list kube-system daemonsets: %w", err)
+		}
+		var list daemonSetList
+		decodeErr := json.NewDecoder(resp.Body).Decode(&list)
+		resp.Body.Close()
+		if decodeErr != nil {
+			return fmt.Errorf("failed to decode daemonset list: %w", decodeErr)
+		}
+
+		allReady := true
+		for _, ds := range list.Items {
+			if ds.Status.NumberReady < ds.Status.DesiredNumberScheduled {
+				log.Printf("DaemonSet %s not yet rolled out: %d/%d ready", ds.Metadata.Name, ds.Status.NumberReady, ds.Status.DesiredNumberScheduled)
+				allReady = false
+			}
+		}
+		if allReady {
+			return nil
+		}
+		time.Sleep(daemonSetPollInterval)
+	}
+	return fmt.Errorf("kube-system daemonsets did not roll out within %s", daemonSetTimeout)
+}
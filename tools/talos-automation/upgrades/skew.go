@@ -0,0 +1,61 @@
+package upgrades
+
+import (
+	"fmt"
+
+	"talos-automation/internal/semver"
+)
+
+// maxMinorHop is the maximum number of minor versions either upgrader will
+// move in a single UpgradeToVersion call. Kubernetes' kubeadm skew policy
+// and Talos' own upgrade guidance both cap this at one minor per hop.
+const maxMinorHop = 1
+
+// validateUpgradePath enforces the shared version-skew policy for both
+// upgraders: no downgrades, and no more than one minor version per hop.
+// component is used only to make the error message readable ("Kubernetes"
+// or "Talos"). forceSkipSkew bypasses the minor-hop check (but never the
+// downgrade check) for operators who know what they're doing.
+func validateUpgradePath(component, current, target string, forceSkipSkew bool) error {
+	if semver.IsDowngrade(current, target) {
+		return fmt.Errorf("refusing to downgrade %s from %s to %s", component, current, target)
+	}
+
+	distance, err := semver.MinorDistance(current, target)
+	if err != nil {
+		return fmt.Errorf("%s version skew check failed: %w", component, err)
+	}
+
+	if distance > maxMinorHop && !forceSkipSkew {
+		intermediate := nextHopVersion(current, target)
+		return fmt.Errorf(
+			"%s upgrade from %s to %s skips %d minor versions; upgrade to %s first, or set FORCE_SKIP_SKEW=true to override",
+			component, current, target, distance, intermediate,
+		)
+	}
+
+	return nil
+}
+
+// nextHopVersion computes the safe intermediate version an operator
+// should upgrade to before continuing on to target: the next minor above
+// current, written as "major.minor.x" (since we have no better guess at
+// the intermediate's latest patch), followed by a note to re-run for
+// target. When current and target share a minor it returns target.
+func nextHopVersion(current, target string) string {
+	currentMinorVersion := semver.Minor(current)
+	targetMinorVersion := semver.Minor(target)
+	if currentMinorVersion == targetMinorVersion {
+		return target
+	}
+	return fmt.Sprintf("%s.x (then re-run to reach %s)", nextMinorString(current), target)
+}
+
+// nextMinorString returns current's minor + 1 as "major.minor", e.g.
+// "1.31.2" -> "1.32".
+func nextMinorString(current string) string {
+	minor := semver.Minor(current)
+	var major, m int
+	fmt.Sscanf(minor, "%d.%d", &major, &m)
+	return fmt.Sprintf("%d.%d", major, m+1)
+}
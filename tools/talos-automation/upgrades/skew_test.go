@@ -0,0 +1,42 @@
+package upgrades
+
+import "testing"
+
+func TestValidateUpgradePathDowngradeAlwaysRejected(t *testing.T) {
+	if err := validateUpgradePath("Kubernetes", "1.32.0", "1.31.0", false); err == nil {
+		t.Error("expected downgrade to be rejected")
+	}
+	if err := validateUpgradePath("Kubernetes", "1.32.0", "1.31.0", true); err == nil {
+		t.Error("expected downgrade to be rejected even with forceSkipSkew")
+	}
+}
+
+func TestValidateUpgradePathSingleMinorHopAllowed(t *testing.T) {
+	if err := validateUpgradePath("Kubernetes", "1.31.0", "1.32.0", false); err != nil {
+		t.Errorf("single minor hop rejected: %v", err)
+	}
+}
+
+func TestValidateUpgradePathMultiMinorHopRejectedUnlessForced(t *testing.T) {
+	if err := validateUpgradePath("Kubernetes", "1.31.0", "1.33.0", false); err == nil {
+		t.Error("expected multi-minor hop to be rejected")
+	}
+	if err := validateUpgradePath("Talos", "1.7.0", "1.9.0", true); err != nil {
+		t.Errorf("expected forceSkipSkew to allow a multi-minor hop: %v", err)
+	}
+}
+
+func TestValidateUpgradePathSameVersionAllowed(t *testing.T) {
+	if err := validateUpgradePath("Talos", "1.9.0", "1.9.0", false); err != nil {
+		t.Errorf("same-version no-op rejected: %v", err)
+	}
+}
+
+func TestNextHopVersion(t *testing.T) {
+	if got := nextHopVersion("1.31.0", "1.31.5"); got != "1.31.5" {
+		t.Errorf("nextHopVersion same minor = %q, want 1.31.5", got)
+	}
+	if got := nextHopVersion("1.31.0", "1.33.0"); got == "" {
+		t.Error("expected a non-empty intermediate hop suggestion")
+	}
+}
@@ -2,8 +2,6 @@ package upgrades
 
 import (
 	"bytes"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -14,17 +12,20 @@ import (
 	"regexp"
 	"strings"
 	"time"
+
+	"talos-automation/internal/repo"
 )
 
 type TalosUpgrader struct {
-	TalosConfigPath    string
-	LogPath            string
-	GitHubClient       GitHubFetcher
-	mockCurrentVersion string
-}
-
-type GitHubFetcher interface {
-	FetchBareMetalConfig(owner, repo string) ([]byte, error)
+	TalosConfigPath      string
+	LogPath              string
+	Source               repo.Source
+	ForceSkipSkew        bool
+	CosignPubKey         string
+	AllowSchematicChange bool
+	ControlPlaneNodes    []string
+	MaxUnavailable       int
+	mockCurrentVersion   string
 }
 
 type ImageFactoryClient struct {
@@ -35,11 +36,11 @@ type SchematicResponse struct {
 	ID string `json:"id"`
 }
 
-func NewTalosUpgrader(talosConfigPath, logPath string, githubClient GitHubFetcher) *TalosUpgrader {
+func NewTalosUpgrader(talosConfigPath, logPath string, source repo.Source) *TalosUpgrader {
 	return &TalosUpgrader{
 		TalosConfigPath: talosConfigPath,
 		LogPath:         logPath,
-		GitHubClient:    githubClient,
+		Source:          source,
 	}
 }
 
@@ -49,7 +50,7 @@ func NewImageFactoryClient() *ImageFactoryClient {
 	}
 }
 
-func (t *TalosUpgrader) UpgradeToVersion(version string, nodes []string, githubOwner, githubRepo string, executeCommands bool) error {
+func (t *TalosUpgrader) UpgradeToVersion(version string, nodes []string, executeCommands bool) error {
 	log.Printf("=== Talos Upgrade Process Started ===")
 	log.Printf("Target version: %s, Node count: %d, Execute commands: %t", version, len(nodes), executeCommands)
 	if !executeCommands && t.mockCurrentVersion != "" {
@@ -88,7 +89,13 @@ func (t *TalosUpgrader) UpgradeToVersion(version string, nodes []string, githubO
 			log.Printf("DECISION: Talos is already at version %s, no upgrade needed", cleanVersion)
 			return nil
 		}
-		
+
+		// Enforce the shared downgrade + minor-skew policy
+		if err := validateUpgradePath("Talos", currentVersion, cleanVersion, t.ForceSkipSkew); err != nil {
+			log.Printf("ERROR: %v", err)
+			return err
+		}
+
 		log.Printf("DECISION: Talos upgrade needed from %s to %s", currentVersion, cleanVersion)
 	}
 
@@ -100,9 +107,15 @@ func (t *TalosUpgrader) UpgradeToVersion(version string, nodes []string, githubO
 	
 	log.Printf("*** PRODUCTION MODE: Executing actual upgrade commands ***")
 
+	journal := NewUpgradeJournal(t.LogPath, "talos")
+	entry, err := journal.StartOrResume(cleanVersion, "", nodes)
+	if err != nil {
+		return fmt.Errorf("failed to start/resume upgrade journal: %w", err)
+	}
+
 	// Only fetch bare-metal config and call Image Factory if we're actually upgrading
 	log.Printf("Fetching bare-metal configuration for upgrade...")
-	bareMetalConfig, err := t.GitHubClient.FetchBareMetalConfig(githubOwner, githubRepo)
+	bareMetalConfig, err := t.Source.FetchBareMetalConfig()
 	if err != nil {
 		return fmt.Errorf("failed to fetch bare-metal config: %w", err)
 	}
@@ -115,20 +128,55 @@ func (t *TalosUpgrader) UpgradeToVersion(version string, nodes []string, githubO
 	}
 
 	log.Printf("Generated schematic ID: %s", schematicID)
+	entry.SchematicID = schematicID
+	if err := journal.RecordPhase(entry, "schematic created"); err != nil {
+		return fmt.Errorf("failed to update upgrade journal: %w", err)
+	}
+
+	log.Printf("Checking schematic for unexpected drift against last pinned state...")
+	if err := checkSchematicDrift(t.LogPath, bareMetalConfig, schematicID, t.AllowSchematicChange); err != nil {
+		return fmt.Errorf("schematic drift check failed: %w", err)
+	}
 
 	// Build installer image URL
 	installerImage := fmt.Sprintf("factory.talos.dev/metal-installer-secureboot/%s:v%s", schematicID, cleanVersion)
 	log.Printf("Using installer image: %s", installerImage)
 
-	// Upgrade each node sequentially
-	for i, node := range nodes {
-		log.Printf("Upgrading node %d/%d: %s", i+1, len(nodes), node)
-		
-		if err := t.upgradeNode(node, installerImage, cleanVersion); err != nil {
-			return fmt.Errorf("failed to upgrade node %s: %w", node, err)
+	if t.CosignPubKey != "" {
+		log.Printf("Verifying installer image signature before upgrading any nodes...")
+		factory := NewImageFactoryClient()
+		if err := factory.VerifyInstaller(installerImage, schematicID, t.CosignPubKey); err != nil {
+			return fmt.Errorf("refusing to proceed with unverified installer image: %w", err)
 		}
-		
-		log.Printf("Successfully upgraded node: %s", node)
+	} else {
+		log.Printf("No cosign public key configured, skipping installer signature verification")
+	}
+
+	// Split the target nodes into control-plane (strictly serial) and
+	// worker (bounded-parallel) subsets, then roll each through the
+	// cordon/drain/upgrade/health-gate/uncordon state machine.
+	controlPlaneSet := make(map[string]bool, len(t.ControlPlaneNodes))
+	for _, node := range t.ControlPlaneNodes {
+		controlPlaneSet[node] = true
+	}
+	var controlPlaneNodes, workerNodes []string
+	for _, node := range nodes {
+		if controlPlaneSet[node] {
+			controlPlaneNodes = append(controlPlaneNodes, node)
+		} else {
+			workerNodes = append(workerNodes, node)
+		}
+	}
+
+	upgradeFn := func(node string) error {
+		return t.upgradeNode(node, installerImage, cleanVersion)
+	}
+	if err := t.rolloutNodes(journal, entry, controlPlaneNodes, workerNodes, cleanVersion, upgradeFn); err != nil {
+		return err
+	}
+
+	if err := journal.Complete(entry); err != nil {
+		return fmt.Errorf("failed to complete upgrade journal: %w", err)
 	}
 
 	log.Printf("Talos upgrade to version %s completed successfully for all nodes", cleanVersion)
@@ -237,23 +285,14 @@ func (t *TalosUpgrader) GetCurrentVersion(executeCommands bool) (string, error)
 	return matches[1], nil
 }
 
+// JournalStatus returns the last recorded Talos upgrade attempt, or nil
+// if none has ever run.
+func (t *TalosUpgrader) JournalStatus() (*JournalEntry, error) {
+	return NewUpgradeJournal(t.LogPath, "talos").Status()
+}
+
 func (t *TalosUpgrader) SetMockCurrentVersion(version string) {
 	log.Printf("SetMockCurrentVersion called: setting mock Talos version to %s", version)
 	t.mockCurrentVersion = version
 	log.Printf("Mock Talos version now set to: %s", t.mockCurrentVersion)
 }
-
-// Method to validate that schematic hasn't changed unexpectedly
-func (t *TalosUpgrader) validateSchematic(bareMetalConfig []byte, expectedHash string) error {
-	// Calculate hash of the bare-metal config
-	hasher := sha256.New()
-	hasher.Write(bareMetalConfig)
-	actualHash := hex.EncodeToString(hasher.Sum(nil))
-
-	if expectedHash != "" && actualHash != expectedHash {
-		log.Printf("Warning: bare-metal config hash changed from %s to %s", expectedHash, actualHash)
-		// Don't fail, just warn - configuration may have legitimately changed
-	}
-
-	return nil
-}
\ No newline at end of file
@@ -0,0 +1,393 @@
+package upgrades
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/sigstore/sigstore/pkg/signature"
+)
+
+// schematicStateFile is the name of the JSON file under LogPath used to
+// pin the schematic ID and bare-metal config hash last accepted by
+// checkSchematicDrift, so drift can be detected on subsequent runs.
+const schematicStateFile = "schematic-state.json"
+
+// SchematicState records the Image Factory schematic ID and bare-metal
+// config hash last accepted by checkSchematicDrift, so a later run can
+// detect an unexpected change to infrastructure/cluster/bare-metal.yaml.
+// It is written before any node is upgraded, whether or not the upgrade
+// that follows succeeds.
+type SchematicState struct {
+	SchematicID         string `json:"schematicId"`
+	BareMetalConfigHash string `json:"bareMetalConfigHash"`
+}
+
+func schematicStatePath(logPath string) string {
+	return filepath.Join(logPath, schematicStateFile)
+}
+
+func loadSchematicState(logPath string) (*SchematicState, error) {
+	data, err := os.ReadFile(schematicStatePath(logPath))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schematic state: %w", err)
+	}
+	var state SchematicState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse schematic state: %w", err)
+	}
+	return &state, nil
+}
+
+func saveSchematicState(logPath string, state *SchematicState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal schematic state: %w", err)
+	}
+	if err := os.WriteFile(schematicStatePath(logPath), data, 0o644); err != nil {
+		return fmt.Errorf("failed to write schematic state: %w", err)
+	}
+	return nil
+}
+
+// checkSchematicDrift compares bareMetalConfig's hash against the last
+// pinned state (if any) and refuses to continue unless it matches or the
+// operator explicitly allows the change. When the check passes it pins
+// the current hash and schematicID right away.
+func checkSchematicDrift(logPath string, bareMetalConfig []byte, schematicID string, allowChange bool) error {
+	hasher := sha256.New()
+	hasher.Write(bareMetalConfig)
+	currentHash := hex.EncodeToString(hasher.Sum(nil))
+
+	previous, err := loadSchematicState(logPath)
+	if err != nil {
+		return err
+	}
+
+	if previous != nil && previous.BareMetalConfigHash != currentHash && !allowChange {
+		return fmt.Errorf(
+			"bare-metal config hash changed from %s to %s (schematic %s -> %s); set ALLOW_SCHEMATIC_CHANGE=true to confirm this is intentional",
+			previous.BareMetalConfigHash, currentHash, previous.SchematicID, schematicID,
+		)
+	}
+
+	return saveSchematicState(logPath, &SchematicState{SchematicID: schematicID, BareMetalConfigHash: currentHash})
+}
+
+// VerifyInstaller resolves installerImage's digest from the registry,
+// fetches its cosign signature from Image Factory, and verifies it
+// against expectedCosignPubKey before the caller is allowed to invoke
+// talosctl upgrade with it.
+func (f *ImageFactoryClient) VerifyInstaller(installerImage, schematicID, expectedCosignPubKey string) error {
+	log.Printf("Verifying signature for installer image: %s", installerImage)
+
+	digest, err := resolveImageDigest(installerImage)
+	if err != nil {
+		return fmt.Errorf("failed to resolve installer image digest: %w", err)
+	}
+	log.Printf("Resolved installer image digest: %s", digest)
+
+	sig, payload, err := fetchCosignSignature(installerImage, digest)
+	if err != nil {
+		return fmt.Errorf("failed to fetch cosign signature: %w", err)
+	}
+
+	if err := verifyCosignSignature(digest, sig, payload, expectedCosignPubKey); err != nil {
+		return fmt.Errorf("signature verification failed for %s: %w", installerImage, err)
+	}
+
+	log.Printf("Installer image %s (schematic %s) passed cosign verification", installerImage, schematicID)
+	return nil
+}
+
+// registryManifestAccept is the set of manifest media types we're willing
+// to accept, covering both OCI and Docker-style image manifests.
+const registryManifestAccept = "application/vnd.oci.image.manifest.v1+json, application/vnd.docker.distribution.manifest.v2+json"
+
+// registryRequest issues method against the given registry/repository
+// manifest tag, transparently completing the Docker v2 bearer-token
+// handshake (anonymous pull token from the realm advertised in the
+// WWW-Authenticate challenge) that most registries, including the one
+// backing Image Factory, require even for public images.
+func registryRequest(method, registry, repository, tag string) (*http.Response, error) {
+	url := fmt.Sprintf("https://%s/v2/%s/manifests/%s", registry, repository, tag)
+
+	do := func(token string) (*http.Response, error) {
+		req, err := http.NewRequest(method, url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create manifest request: %w", err)
+		}
+		req.Header.Set("Accept", registryManifestAccept)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		return http.DefaultClient.Do(req)
+	}
+
+	resp, err := do("")
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach registry: %w", err)
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+
+		token, err := fetchRegistryToken(challenge, repository)
+		if err != nil {
+			return nil, fmt.Errorf("failed to complete registry auth handshake: %w", err)
+		}
+		resp, err = do(token)
+		if err != nil {
+			return nil, fmt.Errorf("failed to reach registry after auth: %w", err)
+		}
+	}
+
+	return resp, nil
+}
+
+// fetchRegistryToken parses a WWW-Authenticate: Bearer challenge (realm,
+// service, scope) and exchanges it for an anonymous pull token, per the
+// Docker Registry v2 token authentication spec.
+func fetchRegistryToken(challenge, repository string) (string, error) {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return "", fmt.Errorf("unsupported auth challenge: %s", challenge)
+	}
+
+	params := map[string]string{}
+	for _, field := range strings.Split(strings.TrimPrefix(challenge, "Bearer "), ",") {
+		kv := strings.SplitN(strings.TrimSpace(field), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		params[kv[0]] = strings.Trim(kv[1], `"`)
+	}
+
+	realm := params["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("auth challenge missing realm: %s", challenge)
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = fmt.Sprintf("repository:%s:pull", repository)
+	}
+
+	tokenURL := fmt.Sprintf("%s?service=%s&scope=%s", realm, params["service"], scope)
+	resp, err := http.Get(tokenURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to reach token endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint returned status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode token response: %w", err)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	return body.AccessToken, nil
+}
+
+// resolveImageDigest issues a manifest HEAD request and reads back the
+// Docker-Content-Digest header.
+func resolveImageDigest(image string) (string, error) {
+	registry, repository, tag, err := splitImageRef(image)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := registryRequest(http.MethodHead, registry, repository, tag)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("registry returned status %d resolving digest for %s", resp.StatusCode, image)
+	}
+
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		return "", fmt.Errorf("registry response for %s did not include a content digest", image)
+	}
+
+	return digest, nil
+}
+
+// fetchCosignSignature fetches the cosign signature manifest tag
+// (sha256-<digest>.sig) from factory.talos.dev, decodes the raw signature
+// bytes embedded in its first layer's annotation, and fetches that
+// layer's blob: the actual cosign "simple signing" payload (a JSON
+// envelope binding the signature to this image's digest) that the
+// signature was computed over.
+func fetchCosignSignature(image, digest string) (sig, payload []byte, err error) {
+	registry, repository, _, err := splitImageRef(image)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sigTag := strings.ReplaceAll(digest, ":", "-") + ".sig"
+
+	resp, err := registryRequest(http.MethodGet, registry, repository, sigTag)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch signature manifest: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("no cosign signature found for %s (status %d)", image, resp.StatusCode)
+	}
+
+	var manifest struct {
+		Layers []struct {
+			Digest      string            `json:"digest"`
+			Annotations map[string]string `json:"annotations"`
+		} `json:"layers"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&manifest); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode signature manifest: %w", err)
+	}
+	if len(manifest.Layers) == 0 {
+		return nil, nil, fmt.Errorf("signature manifest for %s has no layers", image)
+	}
+	layer := manifest.Layers[0]
+
+	encoded, ok := layer.Annotations["dev.cosignproject.cosign/signature"]
+	if !ok {
+		return nil, nil, fmt.Errorf("signature manifest for %s missing cosign signature annotation", image)
+	}
+	sig, err = base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to base64-decode cosign signature for %s: %w", image, err)
+	}
+
+	if layer.Digest == "" {
+		return nil, nil, fmt.Errorf("signature manifest for %s missing layer digest", image)
+	}
+	payload, err = fetchBlob(registry, repository, layer.Digest)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch signed payload for %s: %w", image, err)
+	}
+
+	return sig, payload, nil
+}
+
+// fetchBlob fetches a content-addressed blob (here, the cosign simple
+// signing payload referenced by a signature manifest layer) from the
+// registry, completing the same bearer-token handshake as manifest
+// requests.
+func fetchBlob(registry, repository, digest string) ([]byte, error) {
+	url := fmt.Sprintf("https://%s/v2/%s/blobs/%s", registry, repository, digest)
+
+	do := func(token string) (*http.Response, error) {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create blob request: %w", err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		return http.DefaultClient.Do(req)
+	}
+
+	resp, err := do("")
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach registry: %w", err)
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+
+		token, err := fetchRegistryToken(challenge, repository)
+		if err != nil {
+			return nil, fmt.Errorf("failed to complete registry auth handshake: %w", err)
+		}
+		resp, err = do(token)
+		if err != nil {
+			return nil, fmt.Errorf("failed to reach registry after auth: %w", err)
+		}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("registry returned status %d fetching blob %s", resp.StatusCode, digest)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob body: %w", err)
+	}
+	return body, nil
+}
+
+// verifyCosignSignature verifies sig (the raw signature bytes, already
+// base64-decoded by the caller) over payload, the cosign simple signing
+// envelope fetched alongside it, and cross-checks that the envelope
+// actually attests to digest so a signature for a different image can't
+// be replayed here.
+func verifyCosignSignature(digest string, sig, payload []byte, pubKeyPEM string) error {
+	var envelope struct {
+		Critical struct {
+			Image struct {
+				DockerManifestDigest string `json:"docker-manifest-digest"`
+			} `json:"image"`
+		} `json:"critical"`
+	}
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		return fmt.Errorf("failed to parse cosign signed payload: %w", err)
+	}
+	if envelope.Critical.Image.DockerManifestDigest != digest {
+		return fmt.Errorf("signed payload attests to digest %s, want %s", envelope.Critical.Image.DockerManifestDigest, digest)
+	}
+
+	pubKey, err := cryptoutils.UnmarshalPEMToPublicKey([]byte(pubKeyPEM))
+	if err != nil {
+		return fmt.Errorf("failed to parse cosign public key: %w", err)
+	}
+	verifier, err := signature.LoadVerifier(pubKey, crypto.SHA256)
+	if err != nil {
+		return fmt.Errorf("failed to load cosign public key: %w", err)
+	}
+	return verifier.VerifySignature(bytes.NewReader(sig), bytes.NewReader(payload))
+}
+
+// splitImageRef splits "registry/repository:tag" into its parts.
+func splitImageRef(image string) (registry, repository, tag string, err error) {
+	parts := strings.SplitN(image, "/", 2)
+	if len(parts) != 2 {
+		return "", "", "", fmt.Errorf("invalid image reference: %s", image)
+	}
+	registry = parts[0]
+
+	repoAndTag := parts[1]
+	idx := strings.LastIndex(repoAndTag, ":")
+	if idx == -1 {
+		return "", "", "", fmt.Errorf("image reference missing tag: %s", image)
+	}
+	repository = repoAndTag[:idx]
+	tag = repoAndTag[idx+1:]
+	return registry, repository, tag, nil
+}